@@ -1,16 +1,36 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"log"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/nginxinc/nginx-ns1-gslb/internal/agent"
+	"github.com/nginxinc/nginx-ns1-gslb/internal/metrics"
+	"github.com/nginxinc/nginx-ns1-gslb/internal/output"
 )
 
-var configFile = flag.String("config-file", "", "Path to the agent configuration file")
+var (
+	configFile  = flag.String("config-file", "", "Path to the agent configuration file")
+	dryRun      = flag.Bool("dry-run", false, "Fetch and process the data once and print the data that would be published to stdout, without sending it to any output")
+	once        = flag.Bool("once", false, "Run a single fetch and push cycle and exit. The agent exits with a non-zero code on failure")
+	validate    = flag.Bool("validate", false, "Validate the configuration file, rejecting unknown keys, and exit. All the errors found are listed and the agent exits with a non-zero code if there is any")
+	checkNginx  = flag.Bool("validate-nginx", false, "Used with -validate. Connect to the NGINX Plus instances to check the upstreams or status zones of the feeds exist")
+	watchConfig = flag.Bool("watch-config", false, "Reload the configuration file when it changes. The configuration is always reloaded on SIGHUP")
+)
+
+// configWatchInterval is how often the configuration file is checked for changes when -watch-config is enabled
+const configWatchInterval = 5 * time.Second
+
+// serverShutdownTimeout is the max time to wait for the HTTP server to finish the requests in progress when the agent stops
+const serverShutdownTimeout = 5 * time.Second
 
 func main() {
 	flag.Parse()
@@ -19,25 +39,195 @@ func main() {
 		log.Fatalf("config-file must be specified")
 	}
 
+	if *validate {
+		validateConfig()
+		return
+	}
+
 	globalConfig, err := agent.ParseConfig(configFile)
 	if err != nil {
 		log.Fatalf("error generating the config: %v", err)
 	}
 
+	if *dryRun {
+		// The data is only written to stdout, so no other output (including NS1) is configured or called
+		globalConfig.Outputs = []output.SinkCfg{{Type: output.StdoutType}}
+	}
+
 	a, err := agent.New(globalConfig)
 	if err != nil {
 		log.Fatalf("error creating the agent: %v", err)
 	}
 
-	go handleTermination()
-	a.Run()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go handleTermination(cancel)
+
+	if *dryRun || *once {
+		err = a.RunOnce(ctx)
+		if err != nil {
+			log.Fatalf("error running the agent: %v", err)
+		}
+		return
+	}
+
+	go handleReload(ctx, a)
+
+	if globalConfig.Agent.ListenAddress != "" {
+		server, err := startServer(globalConfig.Agent.ListenAddress, a)
+		if err != nil {
+			log.Fatalf("error starting the HTTP server: %v", err)
+		}
+		defer stopServer(server)
+	}
+
+	a.Run(ctx)
+	log.Printf("Agent stopped")
+}
+
+// startServer starts the HTTP server that exposes the metrics, health and readiness of the agent
+func startServer(address string, a *agent.Agent) (*http.Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/healthz", a.HealthHandler())
+	mux.Handle("/readyz", a.ReadyHandler())
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("error serving HTTP requests: %v", err)
+		}
+	}()
+
+	log.Printf("Serving metrics, health and readiness on %v", listener.Addr())
+	return server, nil
+}
+
+// stopServer shuts down the HTTP server, waiting for the requests in progress to finish
+func stopServer(server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("error stopping the HTTP server: %v", err)
+	}
+}
+
+// handleReload reloads the configuration of the agent on SIGHUP or, if -watch-config is enabled, when the file changes
+func handleReload(ctx context.Context, a *agent.Agent) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+	defer signal.Stop(sigChan)
+
+	// A nil channel is never ready, so the file changes are ignored unless the watcher is enabled
+	var changes <-chan struct{}
+	if *watchConfig {
+		changes = watchConfigFile(ctx, *configFile, configWatchInterval)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case s := <-sigChan:
+			log.Printf("%v signal received, reloading the configuration file %v", s, *configFile)
+		case <-changes:
+			log.Printf("The configuration file %v changed, reloading it", *configFile)
+		}
+		reloadConfig(a)
+	}
+}
+
+// reloadConfig parses the configuration file again and applies it to the agent. The current configuration is kept on error.
+func reloadConfig(a *agent.Agent) {
+	globalConfig, err := agent.ParseConfig(configFile)
+	if err != nil {
+		log.Printf("error reloading the configuration, the current one will be kept: %v", err)
+		return
+	}
+
+	err = a.Reload(globalConfig)
+	if err != nil {
+		log.Printf("error reloading the configuration, the current one will be kept: %v", err)
+		return
+	}
+	log.Printf("Configuration reloaded")
 }
 
-// handleTermination makes the agent exit on SIGTERM or SIGINT
-func handleTermination() {
+// watchConfigFile checks the modification time and size of the file every interval and notifies when any of them changes
+func watchConfigFile(ctx context.Context, path string, interval time.Duration) <-chan struct{} {
+	changes := make(chan struct{}, 1)
+	lastInfo, err := os.Stat(path)
+	if err != nil {
+		log.Printf("error watching the configuration file %v: %v", path, err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				// The file might be in the middle of being replaced, it will be checked again on the next tick
+				continue
+			}
+			if lastInfo != nil && info.ModTime().Equal(lastInfo.ModTime()) && info.Size() == lastInfo.Size() {
+				continue
+			}
+			lastInfo = info
+
+			select {
+			case changes <- struct{}{}:
+			default:
+				// A reload is already pending
+			}
+		}
+	}()
+
+	return changes
+}
+
+// handleTermination stops the agent on SIGTERM or SIGINT. A second signal makes the agent exit immediately.
+func handleTermination(cancel context.CancelFunc) {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
 	s := <-sigChan
 	log.Printf("%v signal received, shutting down the agent", s)
-	os.Exit(0)
+	cancel()
+
+	s = <-sigChan
+	log.Printf("%v signal received again, exiting now", s)
+	os.Exit(1)
+}
+
+// validateConfig validates the configuration file and exits with a non-zero code if there are errors
+func validateConfig() {
+	globalConfig, errs := agent.ValidateConfig(configFile)
+	if len(errs) == 0 && *checkNginx {
+		errs = agent.CheckNginxResources(globalConfig)
+	}
+
+	if len(errs) > 0 {
+		log.Printf("%d error(s) found in the configuration file %v:", len(errs), *configFile)
+		for _, err := range errs {
+			log.Printf("  - %v", err)
+		}
+		os.Exit(1)
+	}
+
+	log.Printf("The configuration file %v is valid", *configFile)
 }
@@ -1,15 +1,19 @@
 package agent
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
+	"reflect"
+	"sync"
 	"time"
 
 	"github.com/nginxinc/nginx-ns1-gslb/internal"
 	"github.com/nginxinc/nginx-ns1-gslb/internal/input"
+	"github.com/nginxinc/nginx-ns1-gslb/internal/metrics"
 	"github.com/nginxinc/nginx-ns1-gslb/internal/output"
-	"github.com/nginxinc/nginx-plus-go-client/client"
 )
 
 const (
@@ -19,32 +23,91 @@ const (
 	peerUpState          = "up"
 	mergeAvg             = "avg"
 	mergeCount           = "count"
+	httpProtocol         = "http"
+	streamProtocol       = "stream"
 )
 
+// shutdownPushTimeout is the max time to wait for the push of the feeds as down when the agent is stopped
+const shutdownPushTimeout = 10 * time.Second
+
+var errNoInstancesAvailable = errors.New("none of the NGINX Plus instances were available")
+
 // Agent handles all the configuration, I/O and processing of the application
 type Agent struct {
-	fetcher       *input.NginxPlus
-	pusher        *output.NS1
-	cfg           *Cfg
-	services      Services
+	// mu prevents the configuration from being reloaded in the middle of a cycle
+	mu       sync.Mutex
+	fetcher  input.Fetcher
+	pusher   output.Pusher
+	cfg      *Cfg
+	services Services
+	sources  []*feedSource
+	health   health
+	// instances is the number of NGINX instances of the fetcher in the last cycle
+	instances int
+	// stabilizer is nil if neither the hold-down nor the hysteresis are enabled
+	stabilizer *stabilizer
+}
+
+// sourceSettings are the parameters that define how the data of a feed is fetched and merged
+type sourceSettings struct {
+	method       string
+	samplingType string
+	threshold    uint
+	protocol     string
+}
+
+// feedSource is a group of feeds whose data is fetched and merged the same way
+type feedSource struct {
+	sourceSettings
+	// namedServices relates the names of the NGINX resources (or the feed names for the global method) with the feed names
 	namedServices map[string]string
+	// metadata stores the metadata of the feeds that define it, by feed name
+	metadata map[string]*output.FeedMetadata
+	// counters are the counters of the previous cycle used by the rate sampling types, by resource and instance
+	counters map[string]map[string]counters
+	// policies stores the policies of the feeds that define it, by feed name
+	policies map[string]*output.FeedPolicy
+	// policyCounters are the counters of the previous cycle used by the policies, by feed name and instance
+	policyCounters map[string]map[string]counters
+	// metadataCounters are the counters of the previous cycle used by the metadata, by feed name and instance
+	metadataCounters map[string]map[string]counters
+	// quorums stores the quorums of the feeds that define it, by feed name
+	quorums map[string]*quorum
 }
 
 // Cfg stores the configuration parameters for the agent
 type Cfg struct {
-	Interval               uint32 `yaml:"interval"`
-	IntervalMaxRandomDelay uint32 `yaml:"interval_max_random_delay"`
-	RetryTime              uint32 `yaml:"retry_time"`
+	Interval               uint32        `yaml:"interval"`
+	IntervalMaxRandomDelay uint32        `yaml:"interval_max_random_delay"`
+	RetryTime              uint32        `yaml:"retry_time"`
+	PublishDownOnShutdown  bool          `yaml:"publish_down_on_shutdown"`
+	ListenAddress          string        `yaml:"listen_address"`
+	ReadyPushIntervals     uint32        `yaml:"ready_push_intervals"`
+	PushRetries            uint32        `yaml:"push_retries"`
+	PushRetryBackoff       uint32        `yaml:"push_retry_backoff"`
+	HoldDown               HoldDownCfg   `yaml:"hold_down"`
+	Hysteresis             HysteresisCfg `yaml:"hysteresis"`
 }
 
 // configureAll will call configure() methods of fetcher, agent and pusher
-func (agent *Agent) configureAll(fetcherCfg *input.Cfg, pusherCfg *output.Cfg) error {
-	err := agent.fetcher.Configure(fetcherCfg)
+func (agent *Agent) configureAll(fetcherCfg *input.Cfg, outputsCfg []output.SinkCfg, nsoneCfg *output.Cfg) error {
+	// The retries of a fetch don't last longer than an interval
+	fetcherCfg.FetchRetryMaxDuration = time.Duration(agent.cfg.Interval) * time.Second
+
+	var err error
+	agent.fetcher, err = input.New(fetcherCfg, resourcesFor(&agent.services))
 	if err != nil {
 		return fmt.Errorf("fetcher configuration error: %w", err)
 	}
 
-	err = agent.pusher.Configure(pusherCfg)
+	retryCfg := &output.RetryCfg{
+		Retries: agent.cfg.PushRetries,
+		Backoff: time.Duration(agent.cfg.PushRetryBackoff) * time.Second,
+		// The retries of a push don't last longer than an interval, the time they take is subtracted from the wait
+		// until the next cycle
+		MaxDuration: time.Duration(agent.cfg.Interval) * time.Second,
+	}
+	agent.pusher, err = output.New(outputsCfg, nsoneCfg, retryCfg, feedsWithDefaults(&agent.services))
 	if err != nil {
 		return fmt.Errorf("pusher configuration error: %w", err)
 	}
@@ -57,40 +120,127 @@ func (agent *Agent) configureAll(fetcherCfg *input.Cfg, pusherCfg *output.Cfg) e
 	return nil
 }
 
+// resourcesFor returns the stats that need to be fetched from the NGINX instances for the methods of the feeds
+func resourcesFor(services *Services) *input.Resources {
+	resources := &input.Resources{}
+	for _, feed := range feedsWithDefaults(services) {
+		switch {
+		case feed.Method == globalMethod:
+			resources.Connections = true
+			resources.Requests = resources.Requests || feed.SamplingType == mergeRequestsRate ||
+				(feed.Metadata != nil && (feed.Metadata.Weight == requestsRateStat || feed.Metadata.LoadAvg == requestsRateStat))
+		case feed.Method == upstreamGroupsMethod && feed.Protocol == streamProtocol:
+			resources.StreamUpstreams = appendUnique(resources.StreamUpstreams, feed.Name)
+		case feed.Method == upstreamGroupsMethod:
+			resources.Upstreams = appendUnique(resources.Upstreams, feed.Name)
+		case feed.Method == statusZonesMethod && feed.Protocol == streamProtocol:
+			resources.StreamServerZones = appendUnique(resources.StreamServerZones, feed.Name)
+		case feed.Method == statusZonesMethod:
+			resources.ServerZones = appendUnique(resources.ServerZones, feed.Name)
+		}
+	}
+	return resources
+}
+
+func appendUnique(names []string, name string) []string {
+	for _, n := range names {
+		if n == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
+
 func (agent *Agent) configure() error {
-	feedNames, err := agent.pusher.GetFeedsForSourceID(agent.pusher.Cfg.SourceID)
+	feeds := feedsWithDefaults(&agent.services)
+	feedNames := make([]string, 0, len(feeds))
+	for _, feed := range feeds {
+		feedNames = append(feedNames, feed.FeedName)
+	}
+
+	err := agent.pusher.ValidateFeeds(feedNames)
 	if err != nil {
-		return fmt.Errorf("error trying to get Feeds from NS1 for validation: %w", err)
+		return err
 	}
 
-	agent.namedServices = make(map[string]string)
-	for _, svc := range agent.services.Feeds {
-		if _, ok := feedNames[svc.FeedName]; !ok {
-			return fmt.Errorf("feed Name %v not found in NS1 DataFeed with source = %v. Review NS1 configuration", svc.FeedName, agent.pusher.Cfg.SourceID)
+	agent.sources = newFeedSources(feeds)
+	return nil
+}
+
+// newFeedSources groups the feeds that are fetched and merged the same way
+func newFeedSources(feeds []output.Feed) []*feedSource {
+	var sources []*feedSource
+	bySettings := make(map[sourceSettings]*feedSource)
+	for _, feed := range feeds {
+		settings := feedSettings(&feed)
+		src, ok := bySettings[settings]
+		if !ok {
+			src = &feedSource{sourceSettings: settings, namedServices: make(map[string]string)}
+			bySettings[settings] = src
+			sources = append(sources, src)
 		}
-		if agent.services.Method == globalMethod {
-			agent.namedServices[svc.FeedName] = svc.FeedName
+
+		if feed.Method == globalMethod {
+			src.namedServices[feed.FeedName] = feed.FeedName
 		} else {
-			agent.namedServices[svc.Name] = svc.FeedName
+			src.namedServices[feed.Name] = feed.FeedName
+		}
+
+		if feed.Metadata != nil {
+			if src.metadata == nil {
+				src.metadata = make(map[string]*output.FeedMetadata)
+			}
+			src.metadata[feed.FeedName] = feed.Metadata
+		}
+
+		if feed.Policy != nil {
+			if src.policies == nil {
+				src.policies = make(map[string]*output.FeedPolicy)
+			}
+			src.policies[feed.FeedName] = feed.Policy
+		}
+
+		// The quorum was already validated with the configuration
+		if q, err := newQuorum(&feed); err == nil && q != nil {
+			if src.quorums == nil {
+				src.quorums = make(map[string]*quorum)
+			}
+			src.quorums[feed.FeedName] = q
 		}
 	}
+	return sources
+}
 
-	return nil
+// feedSettings returns the settings of a feed with the defaults of the services already applied
+func feedSettings(feed *output.Feed) sourceSettings {
+	settings := sourceSettings{
+		method:       feed.Method,
+		samplingType: feed.SamplingType,
+		protocol:     feed.Protocol,
+	}
+	if feed.Threshold != nil {
+		settings.threshold = *feed.Threshold
+	}
+	return settings
 }
 
-func (agent *Agent) processData(statsSlice []*client.Stats) (map[string]*internal.FeedData, error) {
+func (agent *Agent) processData(statsSlice []*internal.Stats) (map[string]*internal.FeedData, error) {
+	if statsSlice == nil {
+		// If we don't have data to merge (eg: all NGINX Plus instances are offline)
+		return agent.stabilizer.failed(agent.downData(), time.Now()), nil
+	}
+
 	newData := make(map[string]*internal.FeedData)
-	if statsSlice != nil {
-		// If we have data to merge
-		inputData, err := agent.mergeStats(statsSlice)
+	for _, source := range agent.sources {
+		inputData, err := source.mergeStats(statsSlice)
 		if err != nil {
 			return nil, err
 		}
 
-		for src, feed := range agent.namedServices {
+		for src, feed := range source.namedServices {
 			// For the type Global we replicate the same information for all the feeds.
 			var feedData *internal.FeedData
-			if agent.services.Method == globalMethod {
+			if source.method == globalMethod {
 				feedData = inputData[globalMethod]
 			} else {
 				feedData = inputData[src]
@@ -100,89 +250,339 @@ func (agent *Agent) processData(statsSlice []*client.Stats) (map[string]*interna
 				log.Printf("error: [%v] source was not found in the remote NGINX Plus instance(s). Check NGINX Plus config file or agent config file.", src)
 				continue
 			}
+
+			if policy, ok := source.policies[feed]; ok {
+				feedData = source.withPolicy(feed, feedData, policy, statsSlice, src, time.Now())
+			}
+			if metadata, ok := source.metadata[feed]; ok {
+				feedData = source.withMetadata(feed, feedData, metadata, statsSlice, src, time.Now())
+			}
+			if q, ok := source.quorums[feed]; ok {
+				feedData = withQuorum(feed, feedData, q, len(statsSlice), agent.instances)
+			}
 			newData[feed] = feedData
 		}
-	} else {
-		// If we don't have data to merge (eg: all NGINX Plus instances are offline)
-		for _, feed := range agent.namedServices {
-			newData[feed] = &internal.FeedData{
+	}
+	return agent.stabilizer.fetched(newData, time.Now()), nil
+}
+
+// downData returns the data to set all the feeds as down
+func (agent *Agent) downData() map[string]*internal.FeedData {
+	data := make(map[string]*internal.FeedData)
+	for _, source := range agent.sources {
+		for _, feed := range source.namedServices {
+			data[feed] = &internal.FeedData{
 				Up: false,
 			}
 		}
 	}
-	return newData, nil
+	return data
 }
 
-func (agent *Agent) handleErrorAndSleep(err error) {
-	log.Printf("error while running the main loop: %v. No data will be sent this time, will try again in %d seconds", err, agent.cfg.RetryTime)
-	time.Sleep(time.Duration(agent.cfg.RetryTime) * time.Second)
+// sleep waits for the duration d or until ctx is canceled
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
 }
 
-// Run runs the main loop of the agent forever
-func (agent *Agent) Run() {
-	for {
-		input := agent.fetcher.Fetch()
-		if input == nil {
-			fmt.Printf("None of the NGINX Plus instances were available.")
-		}
+// fetch gets the stats from the fetcher, logging when none of the instances is available
+func (agent *Agent) fetch(ctx context.Context) []*internal.Stats {
+	input := agent.fetcher.Fetch(ctx)
+	if ctx.Err() != nil {
+		return input
+	}
 
-		data, err := agent.processData(input)
-		if err != nil {
-			agent.handleErrorAndSleep(err)
-			continue
-		}
+	agent.instances = agent.fetcher.Instances()
+	log.Printf("%d of %d NGINX instances were fetched", len(input), agent.instances)
+	if input == nil {
+		log.Printf("None of the NGINX Plus instances were available.")
+		agent.health.setError(errNoInstancesAvailable)
+	}
+	return input
+}
 
-		err = agent.pusher.Push(data)
-		if err != nil {
-			log.Printf("error pushing the data: %v", err)
-		}
+// RunOnce runs a single fetch, process and push cycle of the agent.
+// An error is returned if the data can't be processed or pushed, or if none of the NGINX Plus instances was available.
+func (agent *Agent) RunOnce(ctx context.Context) error {
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	input := agent.fetch(ctx)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	data, err := agent.processData(input)
+	if err != nil {
+		return fmt.Errorf("error processing the data: %w", err)
+	}
+
+	err = agent.push(ctx, data)
+	if err != nil {
+		return fmt.Errorf("error pushing the data: %w", err)
+	}
+
+	if input == nil {
+		return errNoInstancesAvailable
+	}
+	return nil
+}
+
+// Run runs the main loop of the agent until ctx is canceled. Any fetch or push in progress is aborted when ctx is canceled.
+func (agent *Agent) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		sleep(ctx, agent.runCycle(ctx))
+	}
+
+	log.Printf("Main loop stopped")
 
-		sleepTime := int(agent.cfg.Interval)
-		if agent.cfg.IntervalMaxRandomDelay > 0 {
-			sleepTime += rand.Intn(int(agent.cfg.IntervalMaxRandomDelay)) // #nosec G404
+	if err := agent.publishDown(); err != nil {
+		log.Printf("error publishing the feeds as down: %v", err)
+	}
+}
+
+// runCycle runs a single iteration of the main loop and returns the time to wait until the next one
+func (agent *Agent) runCycle(ctx context.Context) time.Duration {
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	start := time.Now()
+	defer func() {
+		metrics.LoopDuration.Observe(time.Since(start).Seconds())
+		agent.health.cycleDone()
+	}()
+
+	input := agent.fetch(ctx)
+	if ctx.Err() != nil {
+		return 0
+	}
+
+	data, err := agent.processData(input)
+	if err != nil {
+		agent.health.setError(fmt.Errorf("error processing the data: %w", err))
+		log.Printf("error while running the main loop: %v. No data will be sent this time, will try again in %d seconds", err, agent.cfg.RetryTime)
+		return time.Duration(agent.cfg.RetryTime) * time.Second
+	}
+
+	err = agent.push(ctx, data)
+	if err != nil {
+		log.Printf("error pushing the data: %v", err)
+	}
+
+	// The time spent fetching and pushing (including the retries) is part of the interval
+	sleepTime := time.Duration(agent.cfg.Interval)*time.Second - time.Since(start)
+	if sleepTime < 0 {
+		sleepTime = 0
+	}
+	if agent.cfg.IntervalMaxRandomDelay > 0 {
+		sleepTime += time.Duration(rand.Intn(int(agent.cfg.IntervalMaxRandomDelay))) * time.Second // #nosec G404
+	}
+	log.Printf("Loop execution end, sleeping for %v.", sleepTime.Round(time.Millisecond))
+	return sleepTime
+}
+
+// publishDown pushes all the feeds as down if enabled, so the traffic is drained from the PoP before the agent exits
+func (agent *Agent) publishDown() error {
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	if !agent.cfg.PublishDownOnShutdown {
+		return nil
+	}
+
+	// The context of the main loop is already canceled at this point
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownPushTimeout)
+	defer cancel()
+
+	log.Printf("Publishing all the feeds as down before shutting down")
+	return agent.push(ctx, agent.downData())
+}
+
+// push sends the data to the pusher, updating the metrics of the published feeds if it succeeds
+func (agent *Agent) push(ctx context.Context, data map[string]*internal.FeedData) error {
+	err := agent.pusher.Push(ctx, data)
+	if err != nil {
+		agent.health.setError(fmt.Errorf("error pushing the data: %w", err))
+		return err
+	}
+	agent.health.pushDone()
+
+	for feed, feedData := range data {
+		up := 0.0
+		if feedData.Up {
+			up = 1
 		}
-		log.Printf("Loop execution end, sleeping for %v seconds.", sleepTime)
-		time.Sleep(time.Duration(sleepTime) * time.Second)
+		metrics.FeedConnections.WithLabelValues(feed).Set(float64(feedData.Connections))
+		metrics.FeedUp.WithLabelValues(feed).Set(up)
 	}
+	metrics.LastSuccessfulPush.Set(float64(time.Now().Unix()))
+	return nil
 }
 
 // New creates and configures a new Agent (including both, the fetcher and the pusher)
 func New(globalConfig *Config) (*Agent, error) {
 	agent := Agent{
-		cfg:      &globalConfig.Agent,
-		fetcher:  &input.NginxPlus{},
-		pusher:   &output.NS1{},
-		services: globalConfig.Services,
+		cfg:        &globalConfig.Agent,
+		services:   globalConfig.Services,
+		stabilizer: newStabilizer(&globalConfig.Agent),
+	}
+	err := agent.configureAll(&globalConfig.NginxPlus, globalConfig.Outputs, &globalConfig.Nsone)
+	if err == nil {
+		agent.health.configure(agent.cfg)
 	}
-	err := agent.configureAll(&globalConfig.NginxPlus, &globalConfig.Nsone)
 	return &agent, err
 }
 
-// merge an array of Stats fetched from one or more NGINX Plus instances focusing on the right stats depending on the configured methods
-func (agent *Agent) mergeStats(statsSlice []*client.Stats) (map[string]*internal.FeedData, error) {
+// Reload configures a new fetcher, pusher and services from globalConfig and replaces the ones in use once the running
+// cycle ends. If the new configuration fails, the agent keeps running with the current one. The state of the feeds
+// that didn't change (counters, hold-down data and hysteresis) is kept.
+func (agent *Agent) Reload(globalConfig *Config) error {
+	newAgent, err := New(globalConfig)
+	if err != nil {
+		if newAgent.pusher != nil {
+			_ = newAgent.pusher.Close()
+		}
+		return err
+	}
+
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+
+	newAgent.keepState(agent)
+	oldPusher := agent.pusher
+
+	agent.cfg = newAgent.cfg
+	agent.fetcher = newAgent.fetcher
+	agent.pusher = newAgent.pusher
+	agent.services = newAgent.services
+	agent.sources = newAgent.sources
+	agent.stabilizer = newAgent.stabilizer
+	agent.health.configure(agent.cfg)
+
+	if oldPusher != nil {
+		if err := oldPusher.Close(); err != nil {
+			log.Printf("error closing the previous outputs: %v", err)
+		}
+	}
+
+	// The feeds might have changed, the new values are set on the next push
+	metrics.FeedConnections.Reset()
+	metrics.FeedUp.Reset()
+	return nil
+}
+
+// keepState copies from the current agent the state of the feeds that are configured the same way in the new one
+func (agent *Agent) keepState(current *Agent) {
+	unchanged := unchangedFeeds(&current.services, &agent.services)
+	for _, src := range agent.sources {
+		var old *feedSource
+		for _, s := range current.sources {
+			if s.sourceSettings == src.sourceSettings {
+				old = s
+			}
+		}
+		if old == nil {
+			continue
+		}
+
+		for name, feed := range src.namedServices {
+			if !unchanged[feed] {
+				continue
+			}
+			if c, ok := old.counters[name]; ok {
+				if src.counters == nil {
+					src.counters = make(map[string]map[string]counters)
+				}
+				src.counters[name] = c
+			}
+			if c, ok := old.policyCounters[feed]; ok {
+				if src.policyCounters == nil {
+					src.policyCounters = make(map[string]map[string]counters)
+				}
+				src.policyCounters[feed] = c
+			}
+			if c, ok := old.metadataCounters[feed]; ok {
+				if src.metadataCounters == nil {
+					src.metadataCounters = make(map[string]map[string]counters)
+				}
+				src.metadataCounters[feed] = c
+			}
+		}
+	}
+	agent.stabilizer.keep(current.stabilizer, unchanged)
+}
+
+// unchangedFeeds returns the names of the feeds that have the same configuration in both services
+func unchangedFeeds(current, updated *Services) map[string]bool {
+	currentFeeds := make(map[string]output.Feed)
+	for _, feed := range feedsWithDefaults(current) {
+		currentFeeds[feed.FeedName] = feed
+	}
+
+	unchanged := make(map[string]bool)
+	for _, feed := range feedsWithDefaults(updated) {
+		if c, ok := currentFeeds[feed.FeedName]; ok && reflect.DeepEqual(c, feed) {
+			unchanged[feed.FeedName] = true
+		}
+	}
+	return unchanged
+}
+
+// merge an array of Stats fetched from one or more NGINX Plus instances focusing on the right stats depending on the method of the feeds
+func (source *feedSource) mergeStats(statsSlice []*internal.Stats) (map[string]*internal.FeedData, error) {
 	if len(statsSlice) == 0 {
 		return nil, fmt.Errorf("error merging data: no data to merge, empty response")
 	}
 
-	switch agent.services.Method {
+	if source.protocol == streamProtocol {
+		statsSlice = streamStats(statsSlice)
+	}
+
+	var data map[string]*internal.FeedData
+	switch source.method {
 	case globalMethod:
-		return getGlobalConnectionsData(statsSlice), nil
+		data = getGlobalConnectionsData(statsSlice)
 	case upstreamGroupsMethod:
-		return getUpstreamConnectionsData(statsSlice, agent.services.SamplingType, agent.namedServices, int(agent.services.Threshold)), nil
+		data = getUpstreamConnectionsData(statsSlice, source.samplingType, source.namedServices, int(source.threshold))
 	case statusZonesMethod:
-		return getStatusZonesConnectionsData(statsSlice, agent.namedServices), nil
+		data = getStatusZonesConnectionsData(statsSlice, source.namedServices)
+	default:
+		return nil, fmt.Errorf("error processing the data from NGINX Plus instance(s): %v is not a valid NGINX Plus type", source.method)
 	}
 
-	return nil, fmt.Errorf("error processing the data from NGINX Plus instance(s): %v is not a valid NGINX Plus type", agent.services.Method)
+	if isRateSampling(source.samplingType) {
+		source.withRates(data, statsSlice, time.Now())
+	}
+	return data, nil
 }
 
-func getGlobalConnectionsData(statsSlice []*client.Stats) map[string]*internal.FeedData {
+// streamStats returns the stats using the stream upstreams and server zones in place of the HTTP ones,
+// so the same methods can be used for both protocols
+func streamStats(statsSlice []*internal.Stats) []*internal.Stats {
+	streamSlice := make([]*internal.Stats, 0, len(statsSlice))
+	for _, s := range statsSlice {
+		streamSlice = append(streamSlice, &internal.Stats{
+			Instance:    s.Instance,
+			Connections: s.Connections,
+			Requests:    s.Requests,
+			Upstreams:   s.StreamUpstreams,
+			ServerZones: s.StreamServerZones,
+		})
+	}
+	return streamSlice
+}
+
+func getGlobalConnectionsData(statsSlice []*internal.Stats) map[string]*internal.FeedData {
 	data := make(map[string]*internal.FeedData)
 	feedData := &internal.FeedData{
 		Up: true,
 	}
 	for _, s := range statsSlice {
-		feedData.Connections += s.Connections.Active
+		feedData.Connections += s.Connections
 	}
 	data[globalMethod] = feedData
 	return data
@@ -194,7 +594,7 @@ type UpstreamsConnections struct {
 	AvailablePeers int
 }
 
-func getUpstreamConnectionsData(statsSlice []*client.Stats, mergeMethod string, namedServices map[string]string, peerThreshold int) map[string]*internal.FeedData {
+func getUpstreamConnectionsData(statsSlice []*internal.Stats, mergeMethod string, namedServices map[string]string, peerThreshold int) map[string]*internal.FeedData {
 	data := make(map[string]*internal.FeedData)
 	upstreamConnections := make(map[string]*UpstreamsConnections)
 
@@ -203,7 +603,12 @@ func getUpstreamConnectionsData(statsSlice []*client.Stats, mergeMethod string,
 			if _, ok := namedServices[key]; !ok {
 				continue
 			}
-			uc := &UpstreamsConnections{}
+			// The same upstream can be present in more than one NGINX Plus instance, the stats of all of them are added up
+			uc, ok := upstreamConnections[key]
+			if !ok {
+				uc = &UpstreamsConnections{}
+				upstreamConnections[key] = uc
+			}
 
 			for _, p := range ups.Peers {
 				if p.State == peerUpState {
@@ -211,8 +616,6 @@ func getUpstreamConnectionsData(statsSlice []*client.Stats, mergeMethod string,
 					uc.AvailablePeers++
 				}
 			}
-
-			upstreamConnections[key] = uc
 		}
 	}
 
@@ -235,7 +638,7 @@ func getUpstreamConnectionsData(statsSlice []*client.Stats, mergeMethod string,
 	return data
 }
 
-func getStatusZonesConnectionsData(statsSlice []*client.Stats, namedServices map[string]string) map[string]*internal.FeedData {
+func getStatusZonesConnectionsData(statsSlice []*internal.Stats, namedServices map[string]string) map[string]*internal.FeedData {
 	data := make(map[string]*internal.FeedData)
 
 	for _, s := range statsSlice {
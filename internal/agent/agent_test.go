@@ -1,13 +1,18 @@
 package agent
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/nginxinc/nginx-ns1-gslb/internal"
 	"github.com/nginxinc/nginx-ns1-gslb/internal/input"
 	"github.com/nginxinc/nginx-ns1-gslb/internal/output"
-	"github.com/nginxinc/nginx-plus-go-client/client"
 )
 
 func TestNewAgentFailureNoNGINXPlus(t *testing.T) {
@@ -26,7 +31,7 @@ func TestNewAgentFailureNoNGINXPlus(t *testing.T) {
 
 func TestProcessData(t *testing.T) {
 	testCases := []struct {
-		input    []*client.Stats
+		input    []*internal.Stats
 		expected map[string]*internal.FeedData
 		nType    string
 		msg      string
@@ -58,15 +63,11 @@ func TestProcessData(t *testing.T) {
 	servicesFeedsMap := map[string]string{
 		"service01": "feed01",
 	}
-	agent := &Agent{
-		namedServices: servicesFeedsMap,
-		services: Services{
-			Threshold: 1,
-		},
-	}
 
 	for _, testCase := range testCases {
-		agent.services.Method = testCase.nType
+		source := createFeedSource(testCase.nType, "", 1)
+		source.namedServices = servicesFeedsMap
+		agent := &Agent{sources: []*feedSource{source}}
 		feedData, _ := agent.processData(testCase.input)
 		if !reflect.DeepEqual(testCase.expected, feedData) {
 			t.Errorf("agent.processData returned %v, but %v expected for case: %v", feedData, testCase.expected, testCase.msg)
@@ -74,6 +75,42 @@ func TestProcessData(t *testing.T) {
 	}
 }
 
+func TestProcessDataMixedMethods(t *testing.T) {
+	threshold := uint(1)
+	feeds := []output.Feed{
+		{FeedName: "feed01", Method: globalMethod},
+		{Name: "service01", FeedName: "feed02", Method: upstreamGroupsMethod, Threshold: &threshold, SamplingType: mergeCount},
+		{Name: "service01", FeedName: "feed03", Method: statusZonesMethod},
+	}
+	agent := &Agent{sources: newFeedSources(feeds)}
+	if len(agent.sources) != 3 {
+		t.Fatalf("newFeedSources returned %v sources, but 3 expected", len(agent.sources))
+	}
+
+	statsSlice := []*internal.Stats{
+		{
+			Connections: 10,
+			Upstreams: map[string]internal.Upstream{
+				"service01": {Peers: []internal.Peer{{State: peerUpState, Active: 3}}},
+			},
+			ServerZones: map[string]internal.ServerZone{"service01": {Processing: 4}},
+		},
+	}
+	expected := map[string]*internal.FeedData{
+		"feed01": {Connections: 10, Up: true},
+		"feed02": {Connections: 3, Up: true},
+		"feed03": {Connections: 4, Up: true},
+	}
+
+	feedData, err := agent.processData(statsSlice)
+	if err != nil {
+		t.Errorf("agent.processData returned an err: %v", err)
+	}
+	if !reflect.DeepEqual(feedData, expected) {
+		t.Errorf("agent.processData returned %v, but %v expected for feeds with different methods", feedData, expected)
+	}
+}
+
 func TestGetUpstreamConnectionsData(t *testing.T) {
 	namedServices := map[string]string{
 		"service01": "feed01",
@@ -81,7 +118,7 @@ func TestGetUpstreamConnectionsData(t *testing.T) {
 	}
 
 	testUpstreamConnections := []struct {
-		statsSlice       []*client.Stats
+		statsSlice       []*internal.Stats
 		mergeMethod      string
 		minPeerThreshold int
 		msg              string
@@ -126,11 +163,11 @@ func TestGetUpstreamConnectionsData(t *testing.T) {
 			msg:              "2 NGINX Plus instances with 2 Upstreams with 3 available peers using method: count",
 			expected: map[string]*internal.FeedData{
 				"service01": {
-					Connections: 6,
+					Connections: 9,
 					Up:          true,
 				},
 				"service02": {
-					Connections: 6,
+					Connections: 9,
 					Up:          true,
 				},
 			},
@@ -142,11 +179,11 @@ func TestGetUpstreamConnectionsData(t *testing.T) {
 			msg:              "2 NGINX Plus instances with 2 Upstreams with 3 available peers using method: avg",
 			expected: map[string]*internal.FeedData{
 				"service01": {
-					Connections: 2,
+					Connections: 1,
 					Up:          true,
 				},
 				"service02": {
-					Connections: 2,
+					Connections: 1,
 					Up:          true,
 				},
 			},
@@ -171,15 +208,15 @@ func TestGetUpstreamConnectionsData(t *testing.T) {
 			statsSlice:       createExampleStatsSlice(2, true),
 			mergeMethod:      "avg",
 			minPeerThreshold: 3,
-			msg:              "1 NGINX Plus instance with 2 Upstreams with 2 available peers and 1 unavailable (min peer threshold 3) using method: avg",
+			msg:              "2 NGINX Plus instances with 2 Upstreams with 2 available peers and 1 unavailable each (min peer threshold 3) using method: avg",
 			expected: map[string]*internal.FeedData{
 				"service01": {
 					Connections: 1,
-					Up:          false,
+					Up:          true,
 				},
 				"service02": {
 					Connections: 1,
-					Up:          false,
+					Up:          true,
 				},
 			},
 		},
@@ -193,9 +230,129 @@ func TestGetUpstreamConnectionsData(t *testing.T) {
 	}
 }
 
+func TestGetUpstreamConnectionsDataMultipleInstances(t *testing.T) {
+	namedServices := map[string]string{
+		"service01": "feed01",
+		"service02": "feed02",
+	}
+
+	testCases := []struct {
+		statsSlice       []*internal.Stats
+		mergeMethod      string
+		minPeerThreshold int
+		msg              string
+		expected         map[string]*internal.FeedData
+	}{
+		{
+			statsSlice: []*internal.Stats{
+				createStatsWithUpstreams(map[string][]internal.Peer{"service01": {{Active: 4, State: peerUpState}}}),
+				createStatsWithUpstreams(map[string][]internal.Peer{"service01": {{Active: 6, State: peerUpState}}}),
+			},
+			mergeMethod:      mergeCount,
+			minPeerThreshold: 2,
+			msg:              "threshold is only reached when adding up the peers of all the instances",
+			expected: map[string]*internal.FeedData{
+				"service01": {
+					Connections: 10,
+					Up:          true,
+				},
+			},
+		},
+		{
+			statsSlice: []*internal.Stats{
+				createStatsWithUpstreams(map[string][]internal.Peer{"service01": {{Active: 4, State: peerUpState}}}),
+				createStatsWithUpstreams(map[string][]internal.Peer{"service01": {{Active: 6, State: peerUpState}}}),
+				createStatsWithUpstreams(map[string][]internal.Peer{"service01": {{Active: 20, State: peerUpState}}}),
+			},
+			mergeMethod:      mergeAvg,
+			minPeerThreshold: 1,
+			msg:              "avg is calculated using the available peers of all the instances",
+			expected: map[string]*internal.FeedData{
+				"service01": {
+					Connections: 10,
+					Up:          true,
+				},
+			},
+		},
+		{
+			statsSlice: []*internal.Stats{
+				createStatsWithUpstreams(map[string][]internal.Peer{
+					"service01": {{Active: 4, State: peerUpState}, {Active: 100, State: "unhealthy"}},
+				}),
+				createStatsWithUpstreams(map[string][]internal.Peer{
+					"service01": {{Active: 50, State: "down"}},
+				}),
+			},
+			mergeMethod:      mergeCount,
+			minPeerThreshold: 2,
+			msg:              "peers not up are ignored in all the instances",
+			expected: map[string]*internal.FeedData{
+				"service01": {
+					Connections: 4,
+					Up:          false,
+				},
+			},
+		},
+		{
+			statsSlice: []*internal.Stats{
+				createStatsWithUpstreams(map[string][]internal.Peer{
+					"service01": {{Active: 3, State: peerUpState}},
+					"service02": {{Active: 1, State: peerUpState}},
+				}),
+				createStatsWithUpstreams(map[string][]internal.Peer{
+					"service01": {{Active: 5, State: peerUpState}},
+				}),
+				createStatsWithUpstreams(map[string][]internal.Peer{
+					"service01":      {{Active: 7, State: peerUpState}},
+					"other-upstream": {{Active: 9, State: peerUpState}},
+				}),
+			},
+			mergeMethod:      mergeCount,
+			minPeerThreshold: 1,
+			msg:              "upstreams not present in all the instances and upstreams not configured",
+			expected: map[string]*internal.FeedData{
+				"service01": {
+					Connections: 15,
+					Up:          true,
+				},
+				"service02": {
+					Connections: 1,
+					Up:          true,
+				},
+			},
+		},
+		{
+			statsSlice: []*internal.Stats{
+				createStatsWithUpstreams(map[string][]internal.Peer{"service01": {{Active: 5, State: "down"}}}),
+				createStatsWithUpstreams(map[string][]internal.Peer{"service01": {{Active: 5, State: "down"}}}),
+			},
+			mergeMethod:      mergeAvg,
+			minPeerThreshold: 1,
+			msg:              "no available peers in any instance using method: avg",
+			expected: map[string]*internal.FeedData{
+				"service01": {
+					Connections: 0,
+					Up:          false,
+				},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.msg, func(t *testing.T) {
+			t.Parallel()
+			feedData := getUpstreamConnectionsData(testCase.statsSlice, testCase.mergeMethod, namedServices, testCase.minPeerThreshold)
+			if !reflect.DeepEqual(testCase.expected, feedData) {
+				t.Errorf("getUpstreamConnectionsData returned %v, but %v expected for case: %v", feedData, testCase.expected, testCase.msg)
+			}
+		})
+	}
+}
+
 func TestGetGlobalConnectionsData(t *testing.T) {
 	testGlobalConnections := []struct {
-		statsSlice []*client.Stats
+		statsSlice []*internal.Stats
 		msg        string
 		expected   map[string]*internal.FeedData
 	}{
@@ -236,7 +393,7 @@ func TestGetStatusZonesConnectionsData(t *testing.T) {
 	}
 
 	testStatusZonesConnections := []struct {
-		statsSlice []*client.Stats
+		statsSlice []*internal.Stats
 		msg        string
 		expected   map[string]*internal.FeedData
 	}{
@@ -280,28 +437,461 @@ func TestGetStatusZonesConnectionsData(t *testing.T) {
 
 func TestMergeStatsWrongType(t *testing.T) {
 	slice := createExampleStatsSlice(1, false)
-	a := createAgentWithServices("", "", 0)
-	_, err := a.mergeStats(slice)
+	source := createFeedSource("", "", 0)
+	_, err := source.mergeStats(slice)
 	if err == nil {
 		t.Errorf("mergeStats err is nil, but error expected for the case: Wrong type")
 	}
 }
 
 func TestMergeStatsEmptyStats(t *testing.T) {
-	a := createAgentWithServices("", "", 0)
-	_, err := a.mergeStats(nil)
+	source := createFeedSource("", "", 0)
+	_, err := source.mergeStats(nil)
 	if err == nil {
 		t.Errorf("mergeStats err is nil, but error expected for the case: Empty slice of stats")
 	}
 }
 
-// createExampleStatsSlice is an util function that creates a fake slice of client.Stats for testing.
+func TestRunOnce(t *testing.T) {
+	testCases := []struct {
+		stats    []*internal.Stats
+		pushErr  error
+		expected map[string]*internal.FeedData
+		wantErr  bool
+		msg      string
+	}{
+		{
+			stats: createExampleStatsSlice(2, false),
+			expected: map[string]*internal.FeedData{
+				"feed01": {Connections: 1, Up: true},
+			},
+			wantErr: false,
+			msg:     "data fetched and pushed",
+		},
+		{
+			stats: nil,
+			expected: map[string]*internal.FeedData{
+				"feed01": {Up: false},
+			},
+			wantErr: true,
+			msg:     "no NGINX Plus instances available",
+		},
+		{
+			stats:   createExampleStatsSlice(1, false),
+			pushErr: errors.New("push error"),
+			expected: map[string]*internal.FeedData{
+				"feed01": {Connections: 0, Up: true},
+			},
+			wantErr: true,
+			msg:     "error pushing the data",
+		},
+	}
+
+	for _, testCase := range testCases {
+		pusher := &fakePusher{err: testCase.pushErr}
+		agent := &Agent{
+			cfg:     &Cfg{Interval: 60},
+			fetcher: &fakeFetcher{stats: testCase.stats},
+			pusher:  pusher,
+			sources: []*feedSource{createGlobalFeedSource("feed01")},
+		}
+
+		err := agent.RunOnce(context.Background())
+		if err == nil && testCase.wantErr {
+			t.Errorf("RunOnce err returned <nil>, but an error was expected for case: %v", testCase.msg)
+		}
+		if err != nil && !testCase.wantErr {
+			t.Errorf("RunOnce returned an err: %v for case: %v", err, testCase.msg)
+		}
+		if !reflect.DeepEqual(pusher.pushed, testCase.expected) {
+			t.Errorf("RunOnce pushed %v, but %v expected for case: %v", pusher.pushed, testCase.expected, testCase.msg)
+		}
+	}
+}
+
+func TestRunOnceSlowFetch(t *testing.T) {
+	pusher := &fakePusher{}
+	agent := &Agent{
+		cfg:     &Cfg{Interval: 1},
+		fetcher: &fakeFetcher{stats: createExampleStatsSlice(2, false), delay: 1100 * time.Millisecond},
+		pusher:  pusher,
+		sources: []*feedSource{createGlobalFeedSource("feed01")},
+	}
+
+	if err := agent.RunOnce(context.Background()); err != nil {
+		t.Errorf("RunOnce returned an err: %v when the fetch is slower than the interval", err)
+	}
+	if pusher.pushed == nil {
+		t.Errorf("RunOnce didn't push the data when the fetch is slower than the interval")
+	}
+}
+
+func TestRunCycleSleepTime(t *testing.T) {
+	testCases := []struct {
+		fetchDelay time.Duration
+		maxSleep   time.Duration
+		minSleep   time.Duration
+		msg        string
+	}{
+		{
+			fetchDelay: 300 * time.Millisecond,
+			minSleep:   500 * time.Millisecond,
+			maxSleep:   700 * time.Millisecond,
+			msg:        "time spent in the cycle subtracted from the interval",
+		},
+		{
+			fetchDelay: 1100 * time.Millisecond,
+			minSleep:   0,
+			maxSleep:   0,
+			msg:        "cycle longer than the interval",
+		},
+	}
+
+	for _, testCase := range testCases {
+		agent := &Agent{
+			cfg:     &Cfg{Interval: 1},
+			fetcher: &fakeFetcher{stats: createExampleStatsSlice(2, false), delay: testCase.fetchDelay},
+			pusher:  &fakePusher{},
+			sources: []*feedSource{createGlobalFeedSource("feed01")},
+		}
+
+		sleepTime := agent.runCycle(context.Background())
+		if sleepTime < testCase.minSleep || sleepTime > testCase.maxSleep {
+			t.Errorf("runCycle returned %v, but a value between %v and %v expected for case: %v", sleepTime, testCase.minSleep, testCase.maxSleep, testCase.msg)
+		}
+	}
+}
+
+func TestRunPublishDownOnShutdown(t *testing.T) {
+	testCases := []struct {
+		publishDown bool
+		expected    map[string]*internal.FeedData
+		msg         string
+	}{
+		{
+			publishDown: true,
+			expected: map[string]*internal.FeedData{
+				"feed01": {Up: false},
+			},
+			msg: "feeds published as down on shutdown",
+		},
+		{
+			publishDown: false,
+			expected: map[string]*internal.FeedData{
+				"feed01": {Connections: 1, Up: true},
+			},
+			msg: "last data kept on shutdown",
+		},
+	}
+
+	for _, testCase := range testCases {
+		pusher := &fakePusher{}
+		agent := &Agent{
+			cfg:     &Cfg{Interval: 60, PublishDownOnShutdown: testCase.publishDown},
+			fetcher: &fakeFetcher{stats: createExampleStatsSlice(2, false)},
+			pusher:  pusher,
+			sources: []*feedSource{createGlobalFeedSource("feed01")},
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		start := time.Now()
+		agent.Run(ctx)
+		cancel()
+
+		if time.Since(start) > 10*time.Second {
+			t.Errorf("Run didn't stop when the context was canceled for case: %v", testCase.msg)
+		}
+		if !reflect.DeepEqual(pusher.pushed, testCase.expected) {
+			t.Errorf("Run pushed %v, but %v expected for case: %v", pusher.pushed, testCase.expected, testCase.msg)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("Active connections: 10\n"))
+	}))
+	defer server.Close()
+
+	validCfg := &Config{
+		Agent: Cfg{Interval: 30},
+		NginxPlus: input.Cfg{
+			Type:          input.StubStatusType,
+			Hosts:         []input.NginxHost{{Host: server.Listener.Addr().String()}},
+			ClientTimeout: 1,
+		},
+		Outputs: []output.SinkCfg{{Type: output.FileType, Path: filepath.Join(t.TempDir(), "feeds.json")}},
+		Services: Services{
+			Method: globalMethod,
+			Feeds:  []output.Feed{{FeedName: "feed02"}},
+		},
+	}
+
+	invalidCfg := &Config{
+		NginxPlus: input.Cfg{
+			Hosts: []input.NginxHost{{Host: "nginxplushost"}},
+		},
+		Services: Services{
+			Method: globalMethod,
+			Feeds:  []output.Feed{{FeedName: "feed02"}},
+		},
+	}
+
+	testCases := []struct {
+		cfg           *Config
+		expectedFeeds []*feedSource
+		expectedCfg   *Cfg
+		wantErr       bool
+		msg           string
+	}{
+		{
+			cfg:           validCfg,
+			expectedFeeds: []*feedSource{createGlobalFeedSource("feed02")},
+			expectedCfg:   &Cfg{Interval: 30},
+			wantErr:       false,
+			msg:           "valid configuration applied",
+		},
+		{
+			cfg:           invalidCfg,
+			expectedFeeds: []*feedSource{createGlobalFeedSource("feed01")},
+			expectedCfg:   &Cfg{Interval: 60},
+			wantErr:       true,
+			msg:           "current configuration kept when NGINX Plus is not reachable",
+		},
+	}
+
+	for _, testCase := range testCases {
+		pusher := &fakePusher{}
+		agent := &Agent{
+			cfg:     &Cfg{Interval: 60},
+			fetcher: &fakeFetcher{},
+			pusher:  pusher,
+			sources: []*feedSource{createGlobalFeedSource("feed01")},
+		}
+
+		err := agent.Reload(testCase.cfg)
+		if err == nil && testCase.wantErr {
+			t.Errorf("Reload err returned <nil>, but an error was expected for case: %v", testCase.msg)
+		}
+		if err != nil && !testCase.wantErr {
+			t.Errorf("Reload returned an err: %v for case: %v", err, testCase.msg)
+		}
+		if !reflect.DeepEqual(agent.sources, testCase.expectedFeeds) {
+			t.Errorf("Reload set the feeds %v, but %v expected for case: %v", agent.sources, testCase.expectedFeeds, testCase.msg)
+		}
+		if !reflect.DeepEqual(agent.cfg, testCase.expectedCfg) {
+			t.Errorf("Reload set the agent config %+v, but %+v expected for case: %v", agent.cfg, testCase.expectedCfg, testCase.msg)
+		}
+		if pusher.closed != !testCase.wantErr {
+			t.Errorf("Reload closed the previous pusher=%v, but %v expected for case: %v", pusher.closed, !testCase.wantErr, testCase.msg)
+		}
+	}
+}
+
+func TestReloadKeepState(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("Active connections: 10\n"))
+	}))
+	defer server.Close()
+
+	cfg := &Config{
+		Agent: Cfg{Interval: 30, HoldDown: HoldDownCfg{Cycles: 3}},
+		NginxPlus: input.Cfg{
+			Type:          input.StubStatusType,
+			Hosts:         []input.NginxHost{{Host: server.Listener.Addr().String()}},
+			ClientTimeout: 1,
+		},
+		Outputs: []output.SinkCfg{{Type: output.StdoutType}},
+		Services: Services{
+			Method:       globalMethod,
+			SamplingType: mergeRequestsRate,
+			Feeds:        []output.Feed{{FeedName: "feed01"}, {FeedName: "feed02"}},
+		},
+	}
+
+	agent, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New returned an unexpected error: %v", err)
+	}
+	instanceCounters := map[string]counters{"host": {requests: 10}}
+	agent.sources[0].counters = map[string]map[string]counters{"feed01": instanceCounters, "feed02": instanceCounters}
+	agent.stabilizer.fetched(map[string]*internal.FeedData{"feed01": {Connections: 5, Up: true}, "feed02": {Connections: 7, Up: true}}, time.Now())
+
+	// feed02 changes its sampling type
+	reloaded := *cfg
+	reloaded.Services.Feeds = []output.Feed{{FeedName: "feed01"}, {FeedName: "feed02", SamplingType: mergeCount}}
+	if err := agent.Reload(&reloaded); err != nil {
+		t.Fatalf("Reload returned an unexpected error: %v", err)
+	}
+
+	for _, src := range agent.sources {
+		_, kept := src.counters["feed02"]
+		if src.samplingType == mergeRequestsRate && !reflect.DeepEqual(src.counters["feed01"], instanceCounters) {
+			t.Errorf("Reload set the counters %v, but the counters of the unchanged feed01 expected", src.counters)
+		}
+		if kept {
+			t.Errorf("Reload kept the counters of feed02, but they were expected to be reset after its configuration changed")
+		}
+	}
+
+	data := agent.stabilizer.failed(agent.downData(), time.Now())
+	expected := map[string]*internal.FeedData{"feed01": {Connections: 5, Up: true}, "feed02": {Up: false}}
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("Reload kept the hold-down data %v, but %v expected", data, expected)
+	}
+}
+
+func TestMergeStatsStream(t *testing.T) {
+	statsSlice := []*internal.Stats{
+		{
+			Upstreams: map[string]internal.Upstream{
+				"backend": {Peers: []internal.Peer{{State: peerUpState, Active: 100}}},
+			},
+			ServerZones: map[string]internal.ServerZone{"backend": {Processing: 100}},
+			StreamUpstreams: map[string]internal.Upstream{
+				"backend": {Peers: []internal.Peer{{State: peerUpState, Active: 3}, {State: "unavail"}}},
+			},
+			StreamServerZones: map[string]internal.ServerZone{"backend": {Processing: 4}},
+		},
+		{
+			StreamUpstreams: map[string]internal.Upstream{
+				"backend": {Peers: []internal.Peer{{State: peerUpState, Active: 5}}},
+			},
+			StreamServerZones: map[string]internal.ServerZone{"backend": {Processing: 6}},
+		},
+	}
+
+	testCases := []struct {
+		method   string
+		expected map[string]*internal.FeedData
+		msg      string
+	}{
+		{
+			method:   upstreamGroupsMethod,
+			expected: map[string]*internal.FeedData{"backend": {Connections: 8, Up: true}},
+			msg:      "stream upstreams of all the instances",
+		},
+		{
+			method:   statusZonesMethod,
+			expected: map[string]*internal.FeedData{"backend": {Connections: 10, Up: true}},
+			msg:      "stream server zones of all the instances",
+		},
+	}
+
+	for _, testCase := range testCases {
+		source := createFeedSource(testCase.method, mergeCount, 2)
+		source.protocol = streamProtocol
+		source.namedServices = map[string]string{"backend": "feed01"}
+
+		data, err := source.mergeStats(statsSlice)
+		if err != nil {
+			t.Errorf("mergeStats returned an err: %v for case: %v", err, testCase.msg)
+		}
+		if !reflect.DeepEqual(data, testCase.expected) {
+			t.Errorf("mergeStats returned %v, but %v expected for case: %v", data, testCase.expected, testCase.msg)
+		}
+	}
+}
+
+func TestResourcesFor(t *testing.T) {
+	feeds := []output.Feed{{Name: "backend", FeedName: "feed01"}, {Name: "frontend", FeedName: "feed02"}}
+	testCases := []struct {
+		services *Services
+		expected *input.Resources
+		msg      string
+	}{
+		{
+			services: &Services{Method: globalMethod, Feeds: []output.Feed{{FeedName: "feed01"}}},
+			expected: &input.Resources{Connections: true},
+			msg:      "global method",
+		},
+		{
+			services: &Services{Method: globalMethod, SamplingType: mergeRequestsRate, Feeds: []output.Feed{{FeedName: "feed01"}}},
+			expected: &input.Resources{Connections: true, Requests: true},
+			msg:      "global method with requests rate",
+		},
+		{
+			services: &Services{Method: globalMethod, Feeds: []output.Feed{{FeedName: "feed01", Metadata: &output.FeedMetadata{Weight: requestsRateStat}}}},
+			expected: &input.Resources{Connections: true, Requests: true},
+			msg:      "global method with requests rate metadata",
+		},
+		{
+			services: &Services{Method: upstreamGroupsMethod, Feeds: feeds},
+			expected: &input.Resources{Upstreams: []string{"backend", "frontend"}},
+			msg:      "upstream_groups method",
+		},
+		{
+			services: &Services{Method: statusZonesMethod, Feeds: feeds},
+			expected: &input.Resources{ServerZones: []string{"backend", "frontend"}},
+			msg:      "status_zones method",
+		},
+		{
+			services: &Services{Method: upstreamGroupsMethod, Protocol: streamProtocol, Feeds: feeds},
+			expected: &input.Resources{StreamUpstreams: []string{"backend", "frontend"}},
+			msg:      "upstream_groups method with stream protocol",
+		},
+		{
+			services: &Services{Method: statusZonesMethod, Protocol: streamProtocol, Feeds: feeds},
+			expected: &input.Resources{StreamServerZones: []string{"backend", "frontend"}},
+			msg:      "status_zones method with stream protocol",
+		},
+	}
+
+	for _, testCase := range testCases {
+		resources := resourcesFor(testCase.services)
+		if !reflect.DeepEqual(resources, testCase.expected) {
+			t.Errorf("resourcesFor returned %+v, but %+v expected for case: %v", resources, testCase.expected, testCase.msg)
+		}
+	}
+}
+
+// fakeFetcher is an input.Fetcher that always returns the same stats after the delay
+type fakeFetcher struct {
+	stats     []*internal.Stats
+	instances int
+	delay     time.Duration
+}
+
+func (ff *fakeFetcher) Fetch(_ context.Context) []*internal.Stats {
+	time.Sleep(ff.delay)
+	return ff.stats
+}
+
+func (ff *fakeFetcher) Instances() int {
+	return ff.instances
+}
+
+// fakePusher is an output.Pusher that stores the last pushed data and returns the configured error
+type fakePusher struct {
+	pushed map[string]*internal.FeedData
+	err    error
+	closed bool
+}
+
+func (fp *fakePusher) Push(ctx context.Context, data map[string]*internal.FeedData) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	fp.pushed = data
+	return fp.err
+}
+
+func (fp *fakePusher) ValidateFeeds(_ []string) error {
+	return nil
+}
+
+func (fp *fakePusher) Close() error {
+	fp.closed = true
+	return nil
+}
+
+// createExampleStatsSlice is an util function that creates a fake slice of internal.Stats for testing.
 // the size of the slice can be set as a parameter, but the number of Upstreams, peers per upstream and zones are fixed
-func createExampleStatsSlice(size uint64, unavailPeer bool) []*client.Stats {
-	var stats []*client.Stats
+func createExampleStatsSlice(size uint64, unavailPeer bool) []*internal.Stats {
+	var stats []*internal.Stats
 	var i uint64
 	for i = 0; i < size; i++ {
-		peers := []client.Peer{
+		peers := []internal.Peer{
 			{Active: i, State: peerUpState},
 			{Active: i + 1, State: peerUpState},
 		}
@@ -309,18 +899,18 @@ func createExampleStatsSlice(size uint64, unavailPeer bool) []*client.Stats {
 		if unavailPeer {
 			state = "down"
 		}
-		peers = append(peers, client.Peer{Active: i + 2, State: state})
+		peers = append(peers, internal.Peer{Active: i + 2, State: state})
 
-		upstreams := map[string]client.Upstream{
+		upstreams := map[string]internal.Upstream{
 			"service01": {Peers: peers},
 			"service02": {Peers: peers},
 		}
-		serverZones := map[string]client.ServerZone{
+		serverZones := map[string]internal.ServerZone{
 			"zone1.org": {Processing: i},
 			"zone2.org": {Processing: i + 1},
 		}
-		newStats := &client.Stats{
-			Connections: client.Connections{Active: i},
+		newStats := &internal.Stats{
+			Connections: i,
 			Upstreams:   upstreams,
 			ServerZones: serverZones,
 		}
@@ -330,17 +920,33 @@ func createExampleStatsSlice(size uint64, unavailPeer bool) []*client.Stats {
 	return stats
 }
 
-// createAgentWithServices returns a new instance of Agent with only services configured.
-func createAgentWithServices(method, sampling string, threshold uint) *Agent {
-	return &Agent{
-		services: Services{
-			Method:       method,
-			SamplingType: sampling,
-			Threshold:    threshold,
-			Feeds: []output.Feed{
-				{Name: "svc1", FeedName: "feed1"},
-				{Name: "svc2", FeedName: "feed2"},
-			},
+// createStatsWithUpstreams is an util function that creates a fake internal.Stats of a single NGINX Plus instance with the given upstreams and peers.
+func createStatsWithUpstreams(upstreamPeers map[string][]internal.Peer) *internal.Stats {
+	upstreams := make(map[string]internal.Upstream)
+	for name, peers := range upstreamPeers {
+		upstreams[name] = internal.Upstream{Peers: peers}
+	}
+	return &internal.Stats{
+		Upstreams: upstreams,
+	}
+}
+
+// createFeedSource returns a new feedSource with two services.
+func createFeedSource(method, sampling string, threshold uint) *feedSource {
+	return &feedSource{
+		sourceSettings: sourceSettings{
+			method:       method,
+			samplingType: sampling,
+			threshold:    threshold,
 		},
+		namedServices: map[string]string{"svc1": "feed1", "svc2": "feed2"},
+	}
+}
+
+// createGlobalFeedSource returns a new feedSource using the global method for the feed.
+func createGlobalFeedSource(feed string) *feedSource {
+	return &feedSource{
+		sourceSettings: sourceSettings{method: globalMethod},
+		namedServices:  map[string]string{feed: feed},
 	}
 }
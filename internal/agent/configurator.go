@@ -1,28 +1,36 @@
 package agent
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net"
 	"os"
+	"strconv"
 
 	"github.com/nginxinc/nginx-ns1-gslb/internal/input"
 	"github.com/nginxinc/nginx-ns1-gslb/internal/output"
 	yaml "gopkg.in/yaml.v2"
 )
 
+const maxPort = 65535
+
 // Services stores the configuration that relates NGINX Plus services with NS1 Data Feeds
 type Services struct {
 	Method       string        `yaml:"method"`
 	Threshold    uint          `yaml:"threshold"`
 	SamplingType string        `yaml:"sampling_type"`
+	Protocol     string        `yaml:"protocol"`
 	Feeds        []output.Feed `yaml:"feeds"`
 }
 
 // Config stores all the parameters from the configuration file
 type Config struct {
-	Agent     Cfg        `yaml:"agent"`
-	NginxPlus input.Cfg  `yaml:"nginx_plus"`
-	Nsone     output.Cfg `yaml:"nsone"`
-	Services  Services   `yaml:"services"`
+	Agent     Cfg              `yaml:"agent"`
+	NginxPlus input.Cfg        `yaml:"nginx_plus"`
+	Nsone     output.Cfg       `yaml:"nsone"`
+	Outputs   []output.SinkCfg `yaml:"outputs"`
+	Services  Services         `yaml:"services"`
 }
 
 // ParseConfig reads the configuration file and return a Config object ready to configure agent and resources
@@ -48,33 +56,387 @@ func ParseConfig(path *string) (*Config, error) {
 	return globalConfig, nil
 }
 
+// ValidateConfig parses the configuration file rejecting unknown keys and returns all the problems found in it at once
+func ValidateConfig(path *string) (*Config, []error) {
+	data, err := os.ReadFile(*path)
+	if err != nil {
+		return nil, []error{fmt.Errorf("error reading file at %v: %w", *path, err)}
+	}
+
+	var errs []error
+	globalConfig := &Config{}
+	err = yaml.UnmarshalStrict(data, globalConfig)
+	if err != nil {
+		// Unknown keys and wrong types are reported all together in a TypeError, the rest of the file is still parsed
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil, []error{fmt.Errorf("error while parsing the configuration file: %w", err)}
+		}
+		for _, e := range typeErr.Errors {
+			errs = append(errs, errors.New(e))
+		}
+	}
+
+	// Unused parameters need to be checked before the defaults are set
+	errs = append(errs, unusedServicesCfgErrors(&globalConfig.Services)...)
+
+	globalConfig = fillWithDefaults(globalConfig)
+
+	errs = append(errs, agentCfgErrors(&globalConfig.Agent)...)
+	errs = append(errs, servicesCfgErrors(globalConfig)...)
+	errs = append(errs, nginxPlusCfgErrors(&globalConfig.NginxPlus)...)
+	for i := range globalConfig.Outputs {
+		if err := globalConfig.Outputs[i].Validate(&globalConfig.Nsone); err != nil {
+			errs = append(errs, fmt.Errorf("output %d: %w", i, err))
+		}
+	}
+
+	return globalConfig, errs
+}
+
+// CheckNginxResources connects to the NGINX Plus instances and checks that the upstreams or status zones referenced
+// by the feeds exist in at least one of them
+func CheckNginxResources(cfg *Config) []error {
+	fetcher, err := input.New(&cfg.NginxPlus, resourcesFor(&cfg.Services))
+	if err != nil {
+		return []error{fmt.Errorf("error connecting to NGINX Plus: %w", err)}
+	}
+
+	statsSlice := fetcher.Fetch(context.Background())
+	if len(statsSlice) == 0 {
+		return []error{errNoInstancesAvailable}
+	}
+
+	var errs []error
+	for _, feed := range feedsWithDefaults(&cfg.Services) {
+		feedStats := statsSlice
+		if feed.Protocol == streamProtocol {
+			feedStats = streamStats(statsSlice)
+		}
+
+		found := false
+		for _, s := range feedStats {
+			switch feed.Method {
+			case upstreamGroupsMethod:
+				_, found = s.Upstreams[feed.Name]
+			case statusZonesMethod:
+				_, found = s.ServerZones[feed.Name]
+			default:
+				found = true
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("[%v] for feed %v not found for method %v in any of the NGINX Plus instances", feed.Name, feed.FeedName, feed.Method))
+		}
+	}
+	return errs
+}
+
+// feedsWithDefaults returns the feeds using the method, threshold, sampling type and protocol of the services
+// for the ones not defined in the feed
+func feedsWithDefaults(services *Services) []output.Feed {
+	feeds := make([]output.Feed, 0, len(services.Feeds))
+	for _, feed := range services.Feeds {
+		if feed.Method == "" {
+			feed.Method = services.Method
+		}
+		if feed.Threshold == nil {
+			threshold := services.Threshold
+			feed.Threshold = &threshold
+		}
+		if feed.SamplingType == "" {
+			feed.SamplingType = services.SamplingType
+		}
+		if feed.Protocol == "" {
+			feed.Protocol = services.Protocol
+		}
+		feeds = append(feeds, feed)
+	}
+	return feeds
+}
+
 func validateServicesCfg(cfg *Config) error {
+	errs := servicesCfgErrors(cfg)
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// servicesCfgErrors returns all the problems found in the Services configuration
+func servicesCfgErrors(cfg *Config) []error {
+	var errs []error
 	if len(cfg.Services.Feeds) == 0 {
-		return fmt.Errorf("at least 1 Feed needs to be defined")
+		errs = append(errs, fmt.Errorf("at least 1 Feed needs to be defined"))
+	}
+
+	usesNS1 := false
+	for _, sink := range cfg.Outputs {
+		if sink.Type == output.NS1Type {
+			usesNS1 = true
+		}
+	}
+
+	// The same wrong value is only reported once, even if it's used by several feeds
+	reported := make(map[string]bool)
+	feeds := feedsWithDefaults(&cfg.Services)
+	for _, feed := range feeds {
+		for _, err := range feedValuesCfgErrors(&feed, cfg.NginxPlus.Type) {
+			if !reported[err.Error()] {
+				reported[err.Error()] = true
+				errs = append(errs, err)
+			}
+		}
+		errs = append(errs, metadataCfgErrors(&feed)...)
+		errs = append(errs, policyCfgErrors(&feed)...)
+		errs = append(errs, quorumCfgErrors(&feed)...)
+		if usesNS1 {
+			errs = append(errs, targetsCfgErrors(&feed, &cfg.Nsone)...)
+		}
+	}
+
+	errs = append(errs, feedNamesCfgErrors(feeds)...)
+	errs = append(errs, resourceNamesCfgErrors(feeds)...)
+	return errs
+}
+
+// feedValuesCfgErrors returns an error for every method, sampling type or protocol of the feed that is not valid
+// for the type of the source
+func feedValuesCfgErrors(feed *output.Feed, sourceType string) []error {
+	var errs []error
+	switch feed.Method {
+	case globalMethod, upstreamGroupsMethod, statusZonesMethod:
+	default:
+		errs = append(errs, fmt.Errorf("method [%v] is not a valid method. Valid methods are: %v, %v, %v", feed.Method, globalMethod, upstreamGroupsMethod, statusZonesMethod))
+	}
+
+	switch {
+	case feed.SamplingType == mergeAvg || feed.SamplingType == mergeCount:
+	case isRateSampling(feed.SamplingType):
+		if !rateSamplingAvailable(feed.SamplingType, feed.Method, feed.Protocol) {
+			errs = append(errs, fmt.Errorf("sampling Type [%v] is not available for method [%v] and protocol [%v]", feed.SamplingType, feed.Method, feed.Protocol))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("sampling Type [%v] is not a valid type. Valid Sampling Types are: %v, %v, %v, %v, %v",
+			feed.SamplingType, mergeAvg, mergeCount, mergeRequestsRate, mergeErrorsRatio, mergeBytesRate))
 	}
 
-	if cfg.Services.SamplingType != mergeAvg && cfg.Services.SamplingType != mergeCount {
-		return fmt.Errorf("sampling Type [%v] is not a valid type. Valid Sampling Types are: %v, %v", cfg.Services.SamplingType, mergeAvg, mergeCount)
+	switch feed.Protocol {
+	// An empty protocol is the same as http
+	case "", httpProtocol, streamProtocol:
+	default:
+		errs = append(errs, fmt.Errorf("protocol [%v] is not a valid protocol. Valid protocols are: %v, %v", feed.Protocol, httpProtocol, streamProtocol))
 	}
 
-	names := make(map[string]bool)
-	for _, feed := range cfg.Services.Feeds {
+	if sourceType == input.StubStatusType && feed.Method != globalMethod {
+		errs = append(errs, fmt.Errorf("method [%v] is not supported by the %v source. Only %v can be used", feed.Method, input.StubStatusType, globalMethod))
+	}
+	return errs
+}
+
+// feedNamesCfgErrors returns an error for every feed without a feed name and for every feed name used by several feeds
+func feedNamesCfgErrors(feeds []output.Feed) []error {
+	var errs []error
+	count := make(map[string]int)
+	for _, feed := range feeds {
 		if feed.FeedName == "" {
-			return fmt.Errorf("feeds must define at least a feed_name")
+			errs = append(errs, fmt.Errorf("feeds must define at least a feed_name"))
+			continue
+		}
+		count[feed.FeedName]++
+		// The data of every feed is published with its feed name, so they can't be shared
+		if count[feed.FeedName] == 2 {
+			errs = append(errs, fmt.Errorf("feed_name [%v] duplicated in Feed List. Feed names must be unique", feed.FeedName))
 		}
+	}
+	return errs
+}
 
-		if cfg.Services.Method != globalMethod {
-			if feed.Name == "" {
-				return fmt.Errorf("feeds must define a name for method: %v", cfg.Services.Method)
-			}
+// resourceNamesCfgErrors returns an error for every feed without the name of the NGINX resource and for every
+// name used by several feeds with the same settings
+func resourceNamesCfgErrors(feeds []output.Feed) []error {
+	var errs []error
+	names := make(map[sourceSettings]map[string]bool)
+	for _, feed := range feeds {
+		if feed.Method == globalMethod {
+			continue
+		}
+		if feed.Name == "" {
+			errs = append(errs, fmt.Errorf("feeds must define a name for method: %v", feed.Method))
+			continue
+		}
 
-			if _, ok := names[feed.Name]; ok {
-				return fmt.Errorf("[%v] duplicated in Feed List. NGINX resources names must be unique", feed.Name)
-			}
-			names[feed.Name] = true
+		// The feeds with the same settings are merged together, so the names must be unique among them
+		settings := feedSettings(&feed)
+		if names[settings] == nil {
+			names[settings] = make(map[string]bool)
+		}
+		if names[settings][feed.Name] {
+			errs = append(errs, fmt.Errorf("[%v] duplicated in Feed List. NGINX resources names must be unique for the same method", feed.Name))
 		}
+		names[settings][feed.Name] = true
 	}
+	return errs
+}
 
+// targetsCfgErrors returns an error for every NS1 target of the feed that is not defined in the nsone section
+func targetsCfgErrors(feed *output.Feed, nsoneCfg *output.Cfg) []error {
+	if len(feed.Targets) == 0 {
+		if len(nsoneCfg.Targets) > 0 && !nsoneCfg.HasTarget(output.DefaultTarget) {
+			return []error{fmt.Errorf("feed %v: targets must be defined when the nsone section doesn't define api_key and source_id", feed.FeedName)}
+		}
+		return nil
+	}
+
+	var errs []error
+	for _, target := range feed.Targets {
+		if !nsoneCfg.HasTarget(target) {
+			errs = append(errs, fmt.Errorf("feed %v: NS1 target [%v] is not defined in the nsone section", feed.FeedName, target))
+		}
+	}
+	return errs
+}
+
+// agentCfgErrors returns all the problems found in the agent configuration
+func agentCfgErrors(cfg *Cfg) []error {
+	var errs []error
+	if cfg.ListenAddress != "" {
+		if _, _, err := net.SplitHostPort(cfg.ListenAddress); err != nil {
+			errs = append(errs, fmt.Errorf("listen_address [%v] must use the format [host]:port: %w", cfg.ListenAddress, err))
+		}
+	}
+	return errs
+}
+
+// unusedServicesCfgErrors returns an error for every parameter that is set but not used by the method of the feeds
+func unusedServicesCfgErrors(services *Services) []error {
+	var errs []error
+	for _, feed := range services.Feeds {
+		method := feed.Method
+		if method == "" {
+			method = services.Method
+		}
+		errs = append(errs, unusedFeedCfgErrors(&feed, method)...)
+	}
+
+	usedThreshold, usedSamplingType, usedByProtocols := usedServicesParams(services)
+	if !usedThreshold && services.Threshold != 0 {
+		errs = append(errs, fmt.Errorf("threshold is only used by method %v, but method is [%v]", upstreamGroupsMethod, services.Method))
+	}
+	if !usedSamplingType && services.SamplingType != "" {
+		errs = append(errs, fmt.Errorf("sampling_type [%v] is only used by method %v, but method is [%v]", services.SamplingType, upstreamGroupsMethod, services.Method))
+	}
+	if !usedByProtocols && services.Protocol != "" {
+		errs = append(errs, fmt.Errorf("protocol is only used by methods %v and %v, but method is [%v]", upstreamGroupsMethod, statusZonesMethod, services.Method))
+	}
+	return errs
+}
+
+// usedServicesParams returns whether the threshold, sampling type and protocol of the services are used by any feed.
+// The parameters of the services are only used by the feeds that don't override them.
+func usedServicesParams(services *Services) (threshold, samplingType, protocol bool) {
+	for _, feed := range services.Feeds {
+		method := feed.Method
+		if method == "" {
+			method = services.Method
+		}
+		threshold = threshold || (method == upstreamGroupsMethod && feed.Threshold == nil)
+		samplingType = samplingType || ((method == upstreamGroupsMethod || isRateSampling(services.SamplingType)) && feed.SamplingType == "")
+		protocol = protocol || (method != globalMethod && feed.Protocol == "")
+	}
+	return threshold, samplingType, protocol
+}
+
+// unusedFeedCfgErrors returns an error for every parameter of the feed that is set but not used by its method
+func unusedFeedCfgErrors(feed *output.Feed, method string) []error {
+	var errs []error
+	if method != upstreamGroupsMethod {
+		if feed.Threshold != nil {
+			errs = append(errs, fmt.Errorf("feed %v: threshold is only used by method %v, but method is [%v]", feed.FeedName, upstreamGroupsMethod, method))
+		}
+		// The rate sampling types can be used by all the methods
+		if feed.SamplingType != "" && !isRateSampling(feed.SamplingType) {
+			errs = append(errs, fmt.Errorf("feed %v: sampling_type [%v] is only used by method %v, but method is [%v]", feed.FeedName, feed.SamplingType, upstreamGroupsMethod, method))
+		}
+	}
+	if method == globalMethod && feed.Protocol != "" {
+		errs = append(errs, fmt.Errorf("feed %v: protocol is only used by methods %v and %v, but method is [%v]", feed.FeedName, upstreamGroupsMethod, statusZonesMethod, method))
+	}
+	return errs
+}
+
+// nginxPlusCfgErrors returns all the problems found in the NGINX Plus configuration
+func nginxPlusCfgErrors(cfg *input.Cfg) []error {
+	var errs []error
+	if cfg.Type != input.NginxPlusType && cfg.Type != input.StubStatusType {
+		errs = append(errs, fmt.Errorf("type [%v] is not a valid source type. Valid types are: %v, %v", cfg.Type, input.NginxPlusType, input.StubStatusType))
+	}
+
+	if len(cfg.Hosts) == 0 {
+		errs = append(errs, fmt.Errorf("at least 1 NGINX Plus host needs to be defined"))
+	}
+
+	errs = append(errs, hostsCfgErrors(cfg.Hosts)...)
+
+	if cfg.ClientTimeout < 0 {
+		errs = append(errs, fmt.Errorf("client_timeout can't be negative"))
+	}
+
+	if cfg.ResolverTimeout < 0 {
+		errs = append(errs, fmt.Errorf("resolver_timeout can't be negative"))
+	}
+
+	if cfg.ResolveInterval < 0 {
+		errs = append(errs, fmt.Errorf("resolve_interval can't be negative"))
+	}
+
+	if cfg.FetchRetries < 0 || cfg.FetchRetryBackoff < 0 {
+		errs = append(errs, fmt.Errorf("fetch_retries and fetch_retry_backoff can't be negative"))
+	}
+
+	if cfg.CircuitBreaker.FailureThreshold < 0 || cfg.CircuitBreaker.OpenTimeout < 0 {
+		errs = append(errs, fmt.Errorf("circuit_breaker failure_threshold and open_timeout can't be negative"))
+	}
+
+	if cfg.Resolver != "" {
+		if err := validateResolver(cfg.Resolver); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// hostsCfgErrors returns all the problems found in the NGINX Plus hosts
+func hostsCfgErrors(hosts []input.NginxHost) []error {
+	var errs []error
+	for i, host := range hosts {
+		if host.Host == "" {
+			errs = append(errs, fmt.Errorf("host %d: host must be defined", i))
+		}
+		if host.Port < 0 || host.Port > maxPort {
+			errs = append(errs, fmt.Errorf("host %d: port %d is out of range (1-%d)", i, host.Port, maxPort))
+		}
+	}
+	return errs
+}
+
+// validateResolver checks the resolver uses the ip:port format
+func validateResolver(resolver string) error {
+	host, port, err := net.SplitHostPort(resolver)
+	if err != nil {
+		return fmt.Errorf("resolver [%v] must use the format ip:port: %w", resolver, err)
+	}
+	if net.ParseIP(host) == nil {
+		return fmt.Errorf("resolver [%v] must use the format ip:port: %v is not a valid IP address", resolver, host)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 1 || p > maxPort {
+		return fmt.Errorf("resolver [%v] must use the format ip:port: port %v is out of range (1-%d)", resolver, port, maxPort)
+	}
 	return nil
 }
 
@@ -83,29 +445,75 @@ func fillWithDefaults(cfg *Config) *Config {
 		cfg.Agent.Interval = 60
 	}
 
-	if cfg.Agent.Interval == 0 {
-		cfg.Agent.Interval = 5
+	if cfg.Agent.RetryTime == 0 {
+		cfg.Agent.RetryTime = 5
 	}
 
-	if cfg.NginxPlus.ClientTimeout == 0 {
-		cfg.NginxPlus.ClientTimeout = 10
+	if cfg.Agent.PushRetryBackoff == 0 {
+		cfg.Agent.PushRetryBackoff = 1
 	}
 
-	if cfg.NginxPlus.ResolverTimeout == 0 {
-		cfg.NginxPlus.ResolverTimeout = 10
+	if cfg.Agent.ReadyPushIntervals == 0 {
+		cfg.Agent.ReadyPushIntervals = 3
 	}
 
-	if cfg.NginxPlus.APIEndpoint == "" {
-		cfg.NginxPlus.APIEndpoint = "/api"
+	fillNginxPlusWithDefaults(&cfg.NginxPlus)
+
+	if cfg.Nsone.PublishOnChange && cfg.Nsone.HeartbeatIntervals == 0 {
+		cfg.Nsone.HeartbeatIntervals = 10
+	}
+
+	if cfg.Nsone.CreateMissingFeeds && len(cfg.Nsone.FeedConfig) == 0 {
+		cfg.Nsone.FeedConfig = map[string]string{"label": output.FeedNamePlaceholder}
 	}
 
 	if cfg.Nsone.ClientTimeout == 0 {
 		cfg.Nsone.ClientTimeout = 10
 	}
 
+	if len(cfg.Outputs) == 0 {
+		cfg.Outputs = []output.SinkCfg{{Type: output.NS1Type}}
+	}
+
+	for i := range cfg.Outputs {
+		if cfg.Outputs[i].ClientTimeout == 0 {
+			cfg.Outputs[i].ClientTimeout = 10
+		}
+	}
+
 	if cfg.Services.SamplingType == "" {
 		cfg.Services.SamplingType = mergeCount
 	}
 
+	if cfg.Services.Protocol == "" {
+		cfg.Services.Protocol = httpProtocol
+	}
+
 	return cfg
 }
+
+func fillNginxPlusWithDefaults(cfg *input.Cfg) {
+	if cfg.Type == "" {
+		cfg.Type = input.NginxPlusType
+	}
+
+	if cfg.ClientTimeout == 0 {
+		cfg.ClientTimeout = 10
+	}
+
+	if cfg.ResolverTimeout == 0 {
+		cfg.ResolverTimeout = 10
+	}
+
+	if cfg.FetchRetryBackoff == 0 {
+		cfg.FetchRetryBackoff = 1
+	}
+
+	if cfg.CircuitBreaker.OpenTimeout == 0 {
+		cfg.CircuitBreaker.OpenTimeout = 60
+	}
+
+	if cfg.APIEndpoint == "" {
+		cfg.APIEndpoint = "/api"
+	}
+}
@@ -2,8 +2,11 @@ package agent
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/nginxinc/nginx-ns1-gslb/internal/input"
 	"github.com/nginxinc/nginx-ns1-gslb/internal/output"
 )
 
@@ -86,6 +89,7 @@ func TestValidateServicesCfg(t *testing.T) {
 		{
 			cfg: &Config{
 				Services: Services{
+					Method: upstreamGroupsMethod,
 					Feeds: []output.Feed{
 						{Name: "svc1", FeedName: "feed01"},
 						{Name: "svc2", FeedName: "feed02"},
@@ -96,6 +100,19 @@ func TestValidateServicesCfg(t *testing.T) {
 			wantErr: false,
 			msg:     "valid input",
 		},
+		{
+			cfg: &Config{
+				Services: Services{
+					Method: "upstreams",
+					Feeds: []output.Feed{
+						{Name: "svc1", FeedName: "feed01"},
+					},
+					SamplingType: "count",
+				},
+			},
+			wantErr: true,
+			msg:     "wrong method",
+		},
 		{
 			cfg: &Config{
 				Services: Services{
@@ -108,9 +125,136 @@ func TestValidateServicesCfg(t *testing.T) {
 			wantErr: true,
 			msg:     "wrong sampling type",
 		},
+		{
+			cfg: &Config{
+				NginxPlus: input.Cfg{
+					Type: input.StubStatusType,
+				},
+				Services: Services{
+					Method: upstreamGroupsMethod,
+					Feeds: []output.Feed{
+						{Name: "svc1", FeedName: "feed01"},
+					},
+					SamplingType: "count",
+				},
+			},
+			wantErr: true,
+			msg:     "method not supported by the source",
+		},
+		{
+			cfg: &Config{
+				Services: Services{
+					Method:   statusZonesMethod,
+					Protocol: "udp",
+					Feeds: []output.Feed{
+						{Name: "svc1", FeedName: "feed01"},
+					},
+					SamplingType: "count",
+				},
+			},
+			wantErr: true,
+			msg:     "wrong protocol",
+		},
+		{
+			cfg: &Config{
+				Services: Services{
+					Method:   statusZonesMethod,
+					Protocol: streamProtocol,
+					Feeds: []output.Feed{
+						{Name: "svc1", FeedName: "feed01"},
+					},
+					SamplingType: "count",
+				},
+			},
+			wantErr: false,
+			msg:     "stream protocol",
+		},
+		{
+			cfg: &Config{
+				Services: Services{
+					Feeds: []output.Feed{
+						{FeedName: "feed01", Method: globalMethod},
+						{Name: "backend", FeedName: "feed02", Method: upstreamGroupsMethod, SamplingType: "avg"},
+						{Name: "backend", FeedName: "feed03", Method: statusZonesMethod, Protocol: streamProtocol},
+					},
+					SamplingType: "count",
+				},
+			},
+			wantErr: false,
+			msg:     "methods defined per feed",
+		},
+		{
+			cfg: &Config{
+				Services: Services{
+					Method: upstreamGroupsMethod,
+					Feeds: []output.Feed{
+						{Name: "backend", FeedName: "feed01"},
+						{Name: "backend", FeedName: "feed02", Method: "upstreams"},
+					},
+					SamplingType: "count",
+				},
+			},
+			wantErr: true,
+			msg:     "wrong method in a feed",
+		},
+		{
+			cfg: &Config{
+				Services: Services{
+					Method: upstreamGroupsMethod,
+					Feeds: []output.Feed{
+						{Name: "backend", FeedName: "feed01"},
+						{Name: "backend", FeedName: "feed02", Protocol: httpProtocol},
+					},
+					SamplingType: "count",
+					Protocol:     httpProtocol,
+				},
+			},
+			wantErr: true,
+			msg:     "duplicated feed resource name with the same settings",
+		},
+		{
+			cfg: &Config{
+				Services: Services{
+					Feeds: []output.Feed{
+						{Name: "backend", FeedName: "feed01", Method: upstreamGroupsMethod},
+						{Name: "backend", FeedName: "feed01", Method: statusZonesMethod},
+					},
+					SamplingType: "count",
+				},
+			},
+			wantErr: true,
+			msg:     "duplicated feed name with different methods",
+		},
+		{
+			cfg: &Config{
+				Services: Services{
+					Feeds: []output.Feed{
+						{FeedName: "feed01", Method: globalMethod, SamplingType: mergeRequestsRate},
+						{Name: "backend", FeedName: "feed02", Method: statusZonesMethod, SamplingType: mergeErrorsRatio},
+					},
+					SamplingType: "count",
+				},
+			},
+			wantErr: false,
+			msg:     "rate sampling types",
+		},
+		{
+			cfg: &Config{
+				Services: Services{
+					Method: globalMethod,
+					Feeds: []output.Feed{
+						{FeedName: "feed01"},
+					},
+					SamplingType: mergeBytesRate,
+				},
+			},
+			wantErr: true,
+			msg:     "rate sampling type not available for the method",
+		},
 	}
 
 	for _, testCase := range testCases {
+		testCase := testCase
 		t.Run(testCase.msg, func(t *testing.T) {
 			t.Parallel()
 			err := validateServicesCfg(testCase.cfg)
@@ -152,6 +296,7 @@ func TestParseExampleConfigs(t *testing.T) {
 	}
 
 	for _, testCase := range testCases {
+		testCase := testCase
 		t.Run(testCase.msg, func(t *testing.T) {
 			t.Parallel()
 			_, err := ParseConfig(&testCase.path)
@@ -161,6 +306,205 @@ func TestParseExampleConfigs(t *testing.T) {
 			if err != nil && !testCase.wantErr {
 				t.Errorf("ParseConfig returned an err: %v for case %v", err, testCase.msg)
 			}
+			_, errs := ValidateConfig(&testCase.path)
+			if len(errs) > 0 && !testCase.wantErr {
+				t.Errorf("ValidateConfig returned errors: %v for case %v", errs, testCase.msg)
+			}
 		})
 	}
 }
+
+func TestValidateConfig(t *testing.T) {
+	testCases := []struct {
+		config     string
+		errorCount int
+		msg        string
+	}{
+		{
+			config: `
+nginx_plus:
+  hosts:
+    - host: "127.0.0.1"
+nsone:
+  api_key: "key"
+  source_id: "source"
+services:
+  method: "upstream_groups"
+  threshold: 1
+  sampling_type: "avg"
+  feeds:
+    - name: "backend"
+      feed_name: "feed01"
+`,
+			errorCount: 0,
+			msg:        "valid configuration",
+		},
+		{
+			config: `
+agent:
+  intervall: 10
+  listen_address: "9090"
+nginx_plus:
+  hosts:
+    - host: "127.0.0.1"
+      port: 70000
+    - host: ""
+  resolver: "8.8.8.8"
+nsone:
+  api_key: "key"
+services:
+  method: "global"
+  threshold: 1
+  sampling_type: "avg"
+  feeds:
+    - feed_name: "feed01"
+`,
+			// unknown key, listen_address without port, port out of range, empty host, resolver without port,
+			// missing source_id, threshold and sampling_type with global method
+			errorCount: 8,
+			msg:        "all the errors are returned at once",
+		},
+		{
+			config: `
+nginx_plus:
+  hosts:
+    - host: "127.0.0.1"
+outputs:
+  - type: "file"
+  - type: "slack"
+services:
+  method: "upstream"
+  feeds:
+    - name: "backend"
+      feed_name: "feed01"
+    - name: "backend"
+      feed_name: "feed02"
+`,
+			// file without path, wrong output type, wrong method, duplicated name
+			errorCount: 4,
+			msg:        "wrong outputs and services",
+		},
+		{
+			config: `
+nginx_plus:
+  hosts:
+    - host: "127.0.0.1"
+outputs:
+  - type: "stdout"
+services:
+  method: "upstream_groups"
+  feeds:
+    - name: "backend"
+      feed_name: "feed01"
+    - name: "backend"
+      feed_name: "feed01"
+      method: "status_zones"
+`,
+			// duplicated feed name
+			errorCount: 1,
+			msg:        "duplicated feed names",
+		},
+		{
+			config: `
+nginx_plus:
+  hosts:
+    - host: "127.0.0.1"
+nsone:
+  api_key: "key"
+  source_id: "source"
+services:
+  method: "global"
+  feeds:
+    - feed_name: "feed01"
+      threshold: 1
+      protocol: "stream"
+    - name: "backend"
+      feed_name: "feed02"
+      method: "status_zones"
+      sampling_type: "avg"
+`,
+			// threshold and protocol with global method, sampling_type with status_zones method
+			errorCount: 3,
+			msg:        "parameters of the feeds not used by their method",
+		},
+		{
+			config: `
+nginx_plus:
+  hosts:
+    - host: "127.0.0.1"
+nsone:
+  targets:
+    - name: "prod"
+      api_key: "key"
+      source_id: "source"
+    - name: "dr"
+      api_key: "other-key"
+      source_id: "other-source"
+services:
+  method: "global"
+  feeds:
+    - feed_name: "feed01"
+      targets: ["prod", "qa"]
+    - feed_name: "feed02"
+`,
+			// undefined target, feed without targets and no default target
+			errorCount: 2,
+			msg:        "feeds with wrong NS1 targets",
+		},
+		{
+			config: `
+nginx_plus:
+  hosts:
+    - host: "127.0.0.1"
+nsone:
+  api_key: "key"
+  source_id: "source"
+  feed_config:
+    label: "{feed_name}"
+services:
+  method: "global"
+  feeds:
+    - feed_name: "feed01"
+`,
+			// feed_config without create_missing_feeds
+			errorCount: 1,
+			msg:        "config of the feeds created without create_missing_feeds",
+		},
+	}
+
+	for _, testCase := range testCases {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte(testCase.config), 0o600); err != nil {
+			t.Fatalf("error writing the configuration file: %v", err)
+		}
+
+		_, errs := ValidateConfig(&path)
+		if len(errs) != testCase.errorCount {
+			t.Errorf("ValidateConfig returned %d errors %v, but %d expected for case: %v", len(errs), errs, testCase.errorCount, testCase.msg)
+		}
+	}
+}
+
+func TestValidateResolver(t *testing.T) {
+	testCases := []struct {
+		resolver string
+		wantErr  bool
+	}{
+		{resolver: "8.8.8.8:53", wantErr: false},
+		{resolver: "[2001:4860:4860::8888]:53", wantErr: false},
+		{resolver: "8.8.8.8", wantErr: true},
+		{resolver: "dns.google:53", wantErr: true},
+		{resolver: "8.8.8.8:0", wantErr: true},
+		{resolver: "8.8.8.8:dns", wantErr: true},
+	}
+
+	for _, testCase := range testCases {
+		err := validateResolver(testCase.resolver)
+		if err == nil && testCase.wantErr {
+			t.Errorf("validateResolver err returned <nil>, but an error was expected for resolver %v", testCase.resolver)
+		}
+		if err != nil && !testCase.wantErr {
+			t.Errorf("validateResolver returned an err: %v for resolver %v", err, testCase.resolver)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// livenessIntervals is the number of intervals without a cycle of the main loop after which the agent is not healthy
+const livenessIntervals = 3
+
+const (
+	statusOK    = "ok"
+	statusError = "error"
+)
+
+// health tracks the progress of the main loop and the last error seen, to report the liveness and readiness of the agent
+type health struct {
+	mu sync.Mutex
+
+	configured         bool
+	interval           time.Duration
+	readyPushIntervals uint32
+
+	started     time.Time
+	lastCycle   time.Time
+	lastPush    time.Time
+	lastErr     error
+	lastErrTime time.Time
+}
+
+// healthStatus is the JSON response of the health and readiness endpoints
+type healthStatus struct {
+	Status             string     `json:"status"`
+	Reason             string     `json:"reason,omitempty"`
+	LastCycle          *time.Time `json:"last_cycle,omitempty"`
+	LastSuccessfulPush *time.Time `json:"last_successful_push,omitempty"`
+	LastError          string     `json:"last_error,omitempty"`
+	LastErrorTime      *time.Time `json:"last_error_time,omitempty"`
+}
+
+// configure stores the parameters of the agent used to check its health once it has been successfully configured
+func (h *health) configure(cfg *Cfg) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.started.IsZero() {
+		h.started = time.Now()
+	}
+	h.configured = true
+	h.interval = time.Duration(cfg.Interval+cfg.IntervalMaxRandomDelay) * time.Second
+	h.readyPushIntervals = cfg.ReadyPushIntervals
+}
+
+func (h *health) cycleDone() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastCycle = time.Now()
+}
+
+func (h *health) pushDone() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastPush = time.Now()
+}
+
+func (h *health) setError(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastErr = err
+	h.lastErrTime = time.Now()
+}
+
+// live returns nil if the main loop is making progress
+func (h *health) live(now time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	last := h.lastCycle
+	if last.IsZero() {
+		last = h.started
+	}
+	if maxGap := livenessIntervals * h.interval; now.Sub(last) > maxGap {
+		return fmt.Errorf("no cycle of the main loop has finished in the last %v", maxGap)
+	}
+	return nil
+}
+
+// ready returns nil if the agent is configured and the data was pushed recently
+func (h *health) ready(now time.Time) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.configured {
+		return fmt.Errorf("the agent is not configured")
+	}
+	if h.lastPush.IsZero() {
+		return fmt.Errorf("the data has not been pushed yet")
+	}
+	if maxGap := time.Duration(h.readyPushIntervals) * h.interval; now.Sub(h.lastPush) > maxGap {
+		return fmt.Errorf("the data has not been pushed successfully in the last %v", maxGap)
+	}
+	return nil
+}
+
+// status builds the response of the endpoints for the result of the check
+func (h *health) status(checkErr error) *healthStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := &healthStatus{Status: statusOK}
+	if checkErr != nil {
+		s.Status = statusError
+		s.Reason = checkErr.Error()
+	}
+	if !h.lastCycle.IsZero() {
+		lastCycle := h.lastCycle
+		s.LastCycle = &lastCycle
+	}
+	if !h.lastPush.IsZero() {
+		lastPush := h.lastPush
+		s.LastSuccessfulPush = &lastPush
+	}
+	if h.lastErr != nil {
+		lastErrTime := h.lastErrTime
+		s.LastError = h.lastErr.Error()
+		s.LastErrorTime = &lastErrTime
+	}
+	return s
+}
+
+func (h *health) handler(check func(now time.Time) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		s := h.status(check(time.Now()))
+
+		code := http.StatusOK
+		if s.Status != statusOK {
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		if err := json.NewEncoder(w).Encode(s); err != nil {
+			log.Printf("error writing the health status: %v", err)
+		}
+	})
+}
+
+// HealthHandler returns an http.Handler that reports if the main loop of the agent is making progress
+func (agent *Agent) HealthHandler() http.Handler {
+	return agent.health.handler(agent.health.live)
+}
+
+// ReadyHandler returns an http.Handler that reports if the agent is configured and has pushed the data recently
+func (agent *Agent) ReadyHandler() http.Handler {
+	return agent.health.handler(agent.health.ready)
+}
@@ -0,0 +1,161 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthLive(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		started   time.Time
+		lastCycle time.Time
+		wantErr   bool
+		msg       string
+	}{
+		{
+			started: now.Add(-time.Minute),
+			wantErr: false,
+			msg:     "recently started without cycles",
+		},
+		{
+			started:   now.Add(-time.Hour),
+			lastCycle: now.Add(-time.Minute),
+			wantErr:   false,
+			msg:       "recent cycle",
+		},
+		{
+			started:   now.Add(-time.Hour),
+			lastCycle: now.Add(-31 * time.Minute),
+			wantErr:   true,
+			msg:       "no cycle in the last 3 intervals",
+		},
+		{
+			started: now.Add(-time.Hour),
+			wantErr: true,
+			msg:     "no cycle since the agent started",
+		},
+	}
+
+	for _, testCase := range testCases {
+		h := &health{
+			configured: true,
+			interval:   10 * time.Minute,
+			started:    testCase.started,
+			lastCycle:  testCase.lastCycle,
+		}
+		err := h.live(now)
+		if err == nil && testCase.wantErr {
+			t.Errorf("live err returned <nil>, but an error was expected for case: %v", testCase.msg)
+		}
+		if err != nil && !testCase.wantErr {
+			t.Errorf("live returned an err: %v for case: %v", err, testCase.msg)
+		}
+	}
+}
+
+func TestHealthReady(t *testing.T) {
+	now := time.Now()
+	testCases := []struct {
+		configured bool
+		lastPush   time.Time
+		wantErr    bool
+		msg        string
+	}{
+		{
+			configured: true,
+			lastPush:   now.Add(-time.Minute),
+			wantErr:    false,
+			msg:        "recent push",
+		},
+		{
+			configured: false,
+			lastPush:   now.Add(-time.Minute),
+			wantErr:    true,
+			msg:        "not configured",
+		},
+		{
+			configured: true,
+			wantErr:    true,
+			msg:        "no push yet",
+		},
+		{
+			configured: true,
+			lastPush:   now.Add(-3 * time.Minute),
+			wantErr:    true,
+			msg:        "no push in the last 2 intervals",
+		},
+	}
+
+	for _, testCase := range testCases {
+		h := &health{
+			configured:         testCase.configured,
+			interval:           time.Minute,
+			readyPushIntervals: 2,
+			lastPush:           testCase.lastPush,
+		}
+		err := h.ready(now)
+		if err == nil && testCase.wantErr {
+			t.Errorf("ready err returned <nil>, but an error was expected for case: %v", testCase.msg)
+		}
+		if err != nil && !testCase.wantErr {
+			t.Errorf("ready returned an err: %v for case: %v", err, testCase.msg)
+		}
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	testCases := []struct {
+		pushErr      error
+		expectedCode int
+		expected     healthStatus
+		msg          string
+	}{
+		{
+			expectedCode: http.StatusOK,
+			expected:     healthStatus{Status: statusOK},
+			msg:          "ready after a successful push",
+		},
+		{
+			pushErr:      errors.New("push error"),
+			expectedCode: http.StatusServiceUnavailable,
+			expected: healthStatus{
+				Status:    statusError,
+				Reason:    "the data has not been pushed yet",
+				LastError: "error pushing the data: push error",
+			},
+			msg: "not ready after a failed push",
+		},
+	}
+
+	for _, testCase := range testCases {
+		agent := &Agent{
+			cfg:     &Cfg{Interval: 60, ReadyPushIntervals: 3},
+			fetcher: &fakeFetcher{stats: createExampleStatsSlice(1, false)},
+			pusher:  &fakePusher{err: testCase.pushErr},
+			sources: []*feedSource{createGlobalFeedSource("feed01")},
+		}
+		agent.health.configure(agent.cfg)
+		_ = agent.RunOnce(context.Background())
+
+		rec := httptest.NewRecorder()
+		agent.ReadyHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+
+		if rec.Code != testCase.expectedCode {
+			t.Errorf("ReadyHandler returned the code %v, but %v expected for case: %v", rec.Code, testCase.expectedCode, testCase.msg)
+		}
+
+		var status healthStatus
+		if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+			t.Fatalf("ReadyHandler returned an invalid JSON response for case: %v: %v", testCase.msg, err)
+		}
+		if status.Status != testCase.expected.Status || status.Reason != testCase.expected.Reason || status.LastError != testCase.expected.LastError {
+			t.Errorf("ReadyHandler returned %+v, but %+v expected for case: %v", status, testCase.expected, testCase.msg)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+	"github.com/nginxinc/nginx-ns1-gslb/internal/output"
+)
+
+// Stats that can be published as the weight or loadavg of a feed
+const (
+	// connectionsStat is the same value published as the connections of the feed
+	connectionsStat = "connections"
+	// healthyPeersStat is the number of peers up of the upstream in all the NGINX Plus instances
+	healthyPeersStat = "healthy_peers"
+	// requestsRateStat is the requests per second since the previous cycle, whatever the sampling type of the feed
+	requestsRateStat = "requests_rate"
+)
+
+// metadataCfgErrors returns an error for every metadata parameter of the feed that is not valid for its method
+func metadataCfgErrors(feed *output.Feed) []error {
+	if feed.Metadata == nil {
+		return nil
+	}
+
+	errs := append(metadataStatErrors(feed, "weight", feed.Metadata.Weight), metadataStatErrors(feed, "loadavg", feed.Metadata.LoadAvg)...)
+
+	high, low := feed.Metadata.HighWatermark, feed.Metadata.LowWatermark
+	if high != nil && low != nil && *low > *high {
+		errs = append(errs, fmt.Errorf("feed %v: low_watermark [%v] can't be greater than high_watermark [%v]", feed.FeedName, *low, *high))
+	}
+	return errs
+}
+
+// metadataStatErrors returns an error if the stat used for the metadata param is not valid for the method of the feed
+func metadataStatErrors(feed *output.Feed, param, stat string) []error {
+	switch stat {
+	case "", connectionsStat:
+	case requestsRateStat:
+		if !rateSamplingAvailable(mergeRequestsRate, feed.Method, feed.Protocol) {
+			return []error{fmt.Errorf("feed %v: %v [%v] is not available for method [%v] and protocol [%v]", feed.FeedName, param, stat, feed.Method, feed.Protocol)}
+		}
+	case healthyPeersStat:
+		if feed.Method != upstreamGroupsMethod {
+			return []error{fmt.Errorf("feed %v: %v [%v] is only available for method %v", feed.FeedName, param, stat, upstreamGroupsMethod)}
+		}
+	default:
+		return []error{fmt.Errorf("feed %v: %v [%v] is not a valid stat. Valid stats are: %v, %v, %v", feed.FeedName, param, stat, connectionsStat, healthyPeersStat, requestsRateStat)}
+	}
+	return nil
+}
+
+// withMetadata returns a copy of feedData with the metadata of the feed. name is the NGINX resource of the feed.
+func (source *feedSource) withMetadata(feed string, feedData *internal.FeedData, metadata *output.FeedMetadata, statsSlice []*internal.Stats, name string, now time.Time) *internal.FeedData {
+	if source.protocol == streamProtocol {
+		statsSlice = streamStats(statsSlice)
+	}
+
+	// The requests rate is calculated once per cycle, as it updates the counters
+	var requestsRate *float64
+	if metadata.Weight == requestsRateStat || metadata.LoadAvg == requestsRateStat {
+		requestsRate = source.metadataRequestsRate(feed, statsSlice, name, now)
+	}
+
+	// The data of the global method is shared by all the feeds, so it can't be modified
+	data := *feedData
+	if metadata.Weight != "" {
+		data.Weight = source.metadataValue(metadata.Weight, &data, statsSlice, name, requestsRate)
+	}
+	if metadata.LoadAvg != "" {
+		data.LoadAvg = source.metadataValue(metadata.LoadAvg, &data, statsSlice, name, requestsRate)
+	}
+	data.HighWatermark = metadata.HighWatermark
+	data.LowWatermark = metadata.LowWatermark
+	return &data
+}
+
+// metadataValue returns the value of the stat for the NGINX resource name, or nil if it's not available in this cycle
+func (source *feedSource) metadataValue(stat string, feedData *internal.FeedData, statsSlice []*internal.Stats, name string, requestsRate *float64) *float64 {
+	var value float64
+	switch stat {
+	case connectionsStat:
+		value = float64(feedData.Connections)
+	case healthyPeersStat:
+		value = float64(healthyPeers(statsSlice, name))
+	case requestsRateStat:
+		return requestsRate
+	}
+	return &value
+}
+
+// metadataRequestsRate returns the requests per second of the NGINX resource name since the previous cycle, or nil
+// if none of the instances has counters of the previous cycle. The counters of this cycle are kept for the next one.
+func (source *feedSource) metadataRequestsRate(feed string, statsSlice []*internal.Stats, name string, now time.Time) *float64 {
+	deltas, current := counterDeltas(statsSlice, source.method, name, source.metadataCounters[feed], now)
+	if source.metadataCounters == nil {
+		source.metadataCounters = make(map[string]map[string]counters)
+	}
+	source.metadataCounters[feed] = current
+
+	if len(deltas) == 0 {
+		return nil
+	}
+	rate := requestsRate(deltas)
+	return &rate
+}
+
+// healthyPeers returns the number of peers up of the upstream in all the NGINX Plus instances
+func healthyPeers(statsSlice []*internal.Stats, upstream string) int {
+	peers := 0
+	for _, s := range statsSlice {
+		for _, p := range s.Upstreams[upstream].Peers {
+			if p.State == peerUpState {
+				peers++
+			}
+		}
+	}
+	return peers
+}
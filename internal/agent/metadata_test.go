@@ -0,0 +1,125 @@
+package agent
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+	"github.com/nginxinc/nginx-ns1-gslb/internal/output"
+)
+
+func TestMetadataCfgErrors(t *testing.T) {
+	high, low := 100.0, 200.0
+	testCases := []struct {
+		feed       output.Feed
+		errorCount int
+		msg        string
+	}{
+		{
+			feed:       output.Feed{FeedName: "feed01", Method: globalMethod},
+			errorCount: 0,
+			msg:        "no metadata",
+		},
+		{
+			feed:       output.Feed{FeedName: "feed01", Method: upstreamGroupsMethod, Metadata: &output.FeedMetadata{Weight: healthyPeersStat, LoadAvg: connectionsStat}},
+			errorCount: 0,
+			msg:        "valid stats",
+		},
+		{
+			feed:       output.Feed{FeedName: "feed01", Method: globalMethod, Metadata: &output.FeedMetadata{LoadAvg: requestsRateStat}},
+			errorCount: 0,
+			msg:        "requests rate with the global method",
+		},
+		{
+			feed:       output.Feed{FeedName: "feed01", Method: statusZonesMethod, Metadata: &output.FeedMetadata{Weight: healthyPeersStat, LoadAvg: "requests"}},
+			errorCount: 2,
+			msg:        "healthy peers without upstreams and unknown stat",
+		},
+		{
+			feed:       output.Feed{FeedName: "feed01", Method: globalMethod, Metadata: &output.FeedMetadata{HighWatermark: &high, LowWatermark: &low}},
+			errorCount: 1,
+			msg:        "low watermark greater than the high watermark",
+		},
+	}
+
+	for _, testCase := range testCases {
+		errs := metadataCfgErrors(&testCase.feed)
+		if len(errs) != testCase.errorCount {
+			t.Errorf("metadataCfgErrors returned %d errors %v, but %d expected for case: %v", len(errs), errs, testCase.errorCount, testCase.msg)
+		}
+	}
+}
+
+func TestProcessDataMetadata(t *testing.T) {
+	high, low := 1000.0, 500.0
+	weight, loadAvg, connections := 2.0, 5.0, 7.0
+	feeds := []output.Feed{
+		{
+			Name:         "backend",
+			FeedName:     "feed01",
+			Method:       upstreamGroupsMethod,
+			SamplingType: mergeCount,
+			Threshold:    new(uint),
+			Metadata:     &output.FeedMetadata{Weight: healthyPeersStat, LoadAvg: connectionsStat, HighWatermark: &high, LowWatermark: &low},
+		},
+		{FeedName: "feed02", Method: globalMethod, Metadata: &output.FeedMetadata{LoadAvg: connectionsStat}},
+		{FeedName: "feed03", Method: globalMethod},
+	}
+	agent := &Agent{sources: newFeedSources(feeds)}
+
+	statsSlice := []*internal.Stats{
+		{
+			Connections: 3,
+			Upstreams: map[string]internal.Upstream{
+				"backend": {Peers: []internal.Peer{{State: peerUpState, Active: 2}, {State: "down", Active: 0}}},
+			},
+		},
+		{
+			Connections: 4,
+			Upstreams: map[string]internal.Upstream{
+				"backend": {Peers: []internal.Peer{{State: peerUpState, Active: 3}}},
+			},
+		},
+	}
+	expected := map[string]*internal.FeedData{
+		"feed01": {Connections: 5, Up: true, Weight: &weight, LoadAvg: &loadAvg, HighWatermark: &high, LowWatermark: &low},
+		"feed02": {Connections: 7, Up: true, LoadAvg: &connections},
+		"feed03": {Connections: 7, Up: true},
+	}
+
+	feedData, err := agent.processData(statsSlice)
+	if err != nil {
+		t.Errorf("agent.processData returned an err: %v", err)
+	}
+	if !reflect.DeepEqual(feedData, expected) {
+		t.Errorf("agent.processData returned %+v, but %+v expected for feeds with metadata", feedData, expected)
+	}
+}
+
+func TestWithMetadataRequestsRate(t *testing.T) {
+	source := newFeedSources([]output.Feed{{FeedName: "feed01", Method: globalMethod, SamplingType: mergeCount}})[0]
+	metadata := &output.FeedMetadata{LoadAvg: requestsRateStat}
+	start := time.Now()
+	rate := 20.0
+
+	cycles := []struct {
+		requests uint64
+		at       time.Time
+		expected *float64
+	}{
+		{requests: 100, at: start, expected: nil},
+		{requests: 300, at: start.Add(10 * time.Second), expected: &rate},
+	}
+
+	for i, cycle := range cycles {
+		statsSlice := []*internal.Stats{{Instance: "host", Connections: 7, Requests: cycle.requests}}
+		data := source.withMetadata("feed01", &internal.FeedData{Connections: 7, Up: true}, metadata, statsSlice, "feed01", cycle.at)
+		if !reflect.DeepEqual(data.LoadAvg, cycle.expected) {
+			t.Errorf("withMetadata returned loadavg %v, but %v expected for cycle %d", data.LoadAvg, cycle.expected, i)
+		}
+		if data.Connections != 7 {
+			t.Errorf("withMetadata returned %v connections, but the active connections expected for cycle %d", data.Connections, i)
+		}
+	}
+}
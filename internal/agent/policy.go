@@ -0,0 +1,132 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+	"github.com/nginxinc/nginx-ns1-gslb/internal/output"
+)
+
+// peerStates are all the states of the peers of an upstream
+var peerStates = []string{peerUpState, "draining", "down", "unavail", "checking", "unhealthy"}
+
+// policyCfgErrors returns an error for every policy parameter of the feed that is not valid for its method
+func policyCfgErrors(feed *output.Feed) []error {
+	policy := feed.Policy
+	if policy == nil {
+		return nil
+	}
+
+	var errs []error
+	if feed.Method != upstreamGroupsMethod && (len(policy.HealthyStates) > 0 || policy.MinHealthyPercent != 0 || policy.MaxResponseTime != 0) {
+		errs = append(errs, fmt.Errorf("feed %v: healthy_states, min_healthy_percent and max_response_time are only used by method %v, but method is [%v]",
+			feed.FeedName, upstreamGroupsMethod, feed.Method))
+	}
+	if policy.MaxErrorsRatio != 0 && !rateSamplingAvailable(mergeErrorsRatio, feed.Method, feed.Protocol) {
+		errs = append(errs, fmt.Errorf("feed %v: max_errors_ratio is not available for method [%v] and protocol [%v]", feed.FeedName, feed.Method, feed.Protocol))
+	}
+
+	for _, state := range policy.HealthyStates {
+		if !containsState(peerStates, state) {
+			errs = append(errs, fmt.Errorf("feed %v: healthy state [%v] is not a valid state. Valid states are: %v", feed.FeedName, state, peerStates))
+		}
+	}
+	if policy.MinHealthyPercent < 0 || policy.MinHealthyPercent > 100 {
+		errs = append(errs, fmt.Errorf("feed %v: min_healthy_percent [%v] must be between 0 and 100", feed.FeedName, policy.MinHealthyPercent))
+	}
+	if policy.MaxErrorsRatio < 0 || policy.MaxErrorsRatio > 100 {
+		errs = append(errs, fmt.Errorf("feed %v: max_errors_ratio [%v] must be between 0 and 100", feed.FeedName, policy.MaxErrorsRatio))
+	}
+	return errs
+}
+
+// withPolicy returns a copy of feedData set as down if the NGINX resource name doesn't meet the policy of the feed.
+// For upstreams, the threshold is applied to the peers in the healthy states of the policy.
+func (source *feedSource) withPolicy(feed string, feedData *internal.FeedData, policy *output.FeedPolicy, statsSlice []*internal.Stats, name string, now time.Time) *internal.FeedData {
+	if source.protocol == streamProtocol {
+		statsSlice = streamStats(statsSlice)
+	}
+
+	data := *feedData
+	var reason string
+	if source.method == upstreamGroupsMethod {
+		data.Up, reason = source.upstreamPolicy(policy, statsSlice, name)
+	}
+
+	if policy.MaxErrorsRatio != 0 {
+		deltas, current := counterDeltas(statsSlice, source.method, name, source.policyCounters[feed], now)
+		if source.policyCounters == nil {
+			source.policyCounters = make(map[string]map[string]counters)
+		}
+		source.policyCounters[feed] = current
+
+		if ratio, ok := errorsRatio(deltas); ok && ratio > policy.MaxErrorsRatio && data.Up {
+			data.Up = false
+			reason = fmt.Sprintf("%.2f%% of the responses have a 5xx status, the max is %v%%", ratio, policy.MaxErrorsRatio)
+		}
+	}
+
+	if feedData.Up && !data.Up {
+		log.Printf("feed %v is down because of its policy: %v", feed, reason)
+	}
+	return &data
+}
+
+// upstreamPolicy returns if the upstream meets the threshold and the peers conditions of the policy, and the reason if not
+func (source *feedSource) upstreamPolicy(policy *output.FeedPolicy, statsSlice []*internal.Stats, name string) (bool, string) {
+	healthyStates := policy.HealthyStates
+	if len(healthyStates) == 0 {
+		healthyStates = []string{peerUpState}
+	}
+
+	var peers, healthy int
+	var responseTime, timedPeers uint64
+	for _, s := range statsSlice {
+		for _, p := range s.Upstreams[name].Peers {
+			peers++
+			if !containsState(healthyStates, p.State) {
+				continue
+			}
+			healthy++
+			// The response time is 0 until the peer gets a request
+			if p.ResponseTime > 0 {
+				responseTime += p.ResponseTime
+				timedPeers++
+			}
+		}
+	}
+
+	if healthy < int(source.threshold) {
+		return false, fmt.Sprintf("%v healthy peers, the threshold is %v", healthy, source.threshold)
+	}
+	if policy.MinHealthyPercent != 0 {
+		if percent := percentage(healthy, peers); percent < policy.MinHealthyPercent {
+			return false, fmt.Sprintf("%.2f%% of the peers are healthy, the min is %v%%", percent, policy.MinHealthyPercent)
+		}
+	}
+	if policy.MaxResponseTime != 0 && timedPeers > 0 {
+		if avg := responseTime / timedPeers; avg > policy.MaxResponseTime {
+			return false, fmt.Sprintf("the average response time is %vms, the max is %vms", avg, policy.MaxResponseTime)
+		}
+	}
+	return true, ""
+}
+
+func containsState(states []string, state string) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
+// percentage returns the percentage of part in total, 0 if total is 0
+func percentage(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total) * 100
+}
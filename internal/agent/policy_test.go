@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+	"github.com/nginxinc/nginx-ns1-gslb/internal/output"
+)
+
+func TestPolicyCfgErrors(t *testing.T) {
+	testCases := []struct {
+		feed       output.Feed
+		errorCount int
+		msg        string
+	}{
+		{
+			feed:       output.Feed{FeedName: "feed01", Method: upstreamGroupsMethod, Policy: &output.FeedPolicy{HealthyStates: []string{"up", "draining"}, MinHealthyPercent: 50, MaxErrorsRatio: 5, MaxResponseTime: 500}},
+			errorCount: 0,
+			msg:        "valid policy",
+		},
+		{
+			feed:       output.Feed{FeedName: "feed01", Method: upstreamGroupsMethod, Policy: &output.FeedPolicy{HealthyStates: []string{"running"}, MinHealthyPercent: 150, MaxErrorsRatio: -1}},
+			errorCount: 3,
+			msg:        "wrong state and values out of range",
+		},
+		{
+			feed:       output.Feed{FeedName: "feed01", Method: statusZonesMethod, Protocol: streamProtocol, Policy: &output.FeedPolicy{MaxErrorsRatio: 5, MinHealthyPercent: 50}},
+			errorCount: 1,
+			msg:        "peers conditions with status zones",
+		},
+		{
+			feed:       output.Feed{FeedName: "feed01", Method: upstreamGroupsMethod, Protocol: streamProtocol, Policy: &output.FeedPolicy{MaxErrorsRatio: 5}},
+			errorCount: 1,
+			msg:        "errors ratio with stream upstreams",
+		},
+		{
+			feed:       output.Feed{FeedName: "feed01", Method: globalMethod, Policy: &output.FeedPolicy{MaxErrorsRatio: 5}},
+			errorCount: 1,
+			msg:        "errors ratio with global",
+		},
+	}
+
+	for _, testCase := range testCases {
+		errs := policyCfgErrors(&testCase.feed)
+		if len(errs) != testCase.errorCount {
+			t.Errorf("policyCfgErrors returned %d errors %v, but %d expected for case: %v", len(errs), errs, testCase.errorCount, testCase.msg)
+		}
+	}
+}
+
+func TestUpstreamPolicy(t *testing.T) {
+	statsSlice := []*internal.Stats{
+		{
+			Upstreams: map[string]internal.Upstream{
+				"backend": {Peers: []internal.Peer{
+					{State: peerUpState, ResponseTime: 100},
+					{State: "draining", ResponseTime: 400},
+				}},
+			},
+		},
+		{
+			Upstreams: map[string]internal.Upstream{
+				"backend": {Peers: []internal.Peer{
+					{State: peerUpState},
+					{State: "unhealthy", ResponseTime: 5000},
+				}},
+			},
+		},
+	}
+
+	testCases := []struct {
+		threshold uint
+		policy    *output.FeedPolicy
+		expected  bool
+		msg       string
+	}{
+		{
+			threshold: 2,
+			policy:    &output.FeedPolicy{},
+			expected:  true,
+			msg:       "up peers meet the threshold",
+		},
+		{
+			threshold: 3,
+			policy:    &output.FeedPolicy{},
+			expected:  false,
+			msg:       "up peers under the threshold",
+		},
+		{
+			threshold: 3,
+			policy:    &output.FeedPolicy{HealthyStates: []string{peerUpState, "draining"}},
+			expected:  true,
+			msg:       "draining peers considered healthy",
+		},
+		{
+			policy:   &output.FeedPolicy{MinHealthyPercent: 75},
+			expected: false,
+			msg:      "healthy percentage under the min",
+		},
+		{
+			policy:   &output.FeedPolicy{HealthyStates: []string{peerUpState, "draining"}, MinHealthyPercent: 75},
+			expected: true,
+			msg:      "healthy percentage meets the min",
+		},
+		{
+			policy:   &output.FeedPolicy{MaxResponseTime: 200},
+			expected: true,
+			msg:      "response time of the healthy peers under the max",
+		},
+		{
+			policy:   &output.FeedPolicy{HealthyStates: []string{peerUpState, "draining"}, MaxResponseTime: 200},
+			expected: false,
+			msg:      "response time of the healthy peers over the max",
+		},
+	}
+
+	for _, testCase := range testCases {
+		source := createFeedSource(upstreamGroupsMethod, mergeCount, testCase.threshold)
+		up, _ := source.upstreamPolicy(testCase.policy, statsSlice, "backend")
+		if up != testCase.expected {
+			t.Errorf("upstreamPolicy returned %v, but %v expected for case: %v", up, testCase.expected, testCase.msg)
+		}
+	}
+}
+
+func TestWithPolicyErrorsRatio(t *testing.T) {
+	start := time.Now()
+	source := createFeedSource(statusZonesMethod, mergeCount, 0)
+	policy := &output.FeedPolicy{MaxErrorsRatio: 5}
+	cycles := []struct {
+		stats    *internal.Stats
+		expected bool
+	}{
+		{stats: createCountersStats("host1", 100, 50, 0), expected: true},
+		{stats: createCountersStats("host1", 200, 52, 0), expected: true},
+		{stats: createCountersStats("host1", 300, 62, 0), expected: false},
+	}
+
+	for i, cycle := range cycles {
+		data := source.withPolicy("feed01", &internal.FeedData{Up: true}, policy, []*internal.Stats{cycle.stats}, "backend", start.Add(time.Duration(i)*time.Minute))
+		if data.Up != cycle.expected {
+			t.Errorf("withPolicy returned Up=%v, but %v expected for cycle %d", data.Up, cycle.expected, i)
+		}
+	}
+}
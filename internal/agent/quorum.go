@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+	"github.com/nginxinc/nginx-ns1-gslb/internal/output"
+)
+
+// Actions when the NGINX instances fetched are under the quorum of a feed
+const (
+	belowQuorumDown     = "down"
+	belowQuorumDegraded = "degraded"
+)
+
+// quorum is the min number of NGINX instances that must be fetched to publish the data of a feed as it is
+type quorum struct {
+	min float64
+	// percent is true if min is a percentage of the instances
+	percent bool
+	action  string
+}
+
+// newQuorum returns the quorum of the feed, or nil if the feed doesn't define it
+func newQuorum(feed *output.Feed) (*quorum, error) {
+	if feed.MinHealthyInstances == "" {
+		return nil, nil
+	}
+
+	q := &quorum{action: feed.BelowQuorum}
+	if q.action == "" {
+		q.action = belowQuorumDown
+	}
+	if q.action != belowQuorumDown && q.action != belowQuorumDegraded {
+		return nil, fmt.Errorf("below_quorum [%v] is not a valid action. Valid actions are: %v, %v", q.action, belowQuorumDown, belowQuorumDegraded)
+	}
+
+	value := strings.TrimSpace(feed.MinHealthyInstances)
+	if strings.HasSuffix(value, "%") {
+		q.percent = true
+		value = strings.TrimSuffix(value, "%")
+	}
+
+	min, err := strconv.ParseFloat(value, 64)
+	if err != nil || min < 0 || (q.percent && min > 100) || (!q.percent && min != math.Trunc(min)) {
+		return nil, fmt.Errorf("min_healthy_instances [%v] must be a number of instances or a percentage between 0%% and 100%%", feed.MinHealthyInstances)
+	}
+	q.min = min
+	return q, nil
+}
+
+// quorumCfgErrors returns an error if the quorum of the feed is not valid
+func quorumCfgErrors(feed *output.Feed) []error {
+	if feed.MinHealthyInstances == "" && feed.BelowQuorum != "" {
+		return []error{fmt.Errorf("feed %v: below_quorum is only used with min_healthy_instances", feed.FeedName)}
+	}
+	if _, err := newQuorum(feed); err != nil {
+		return []error{fmt.Errorf("feed %v: %w", feed.FeedName, err)}
+	}
+	// A degraded feed is only distinguished from a healthy one by its weight
+	if feed.BelowQuorum == belowQuorumDegraded && (feed.Metadata == nil || feed.Metadata.Weight == "") {
+		return []error{fmt.Errorf("feed %v: below_quorum [%v] requires metadata.weight to be defined", feed.FeedName, belowQuorumDegraded)}
+	}
+	return nil
+}
+
+// met returns true if the number of instances fetched of the total meets the quorum
+func (q *quorum) met(fetched, total int) bool {
+	if q.percent {
+		return percentage(fetched, total) >= q.min
+	}
+	return float64(fetched) >= q.min
+}
+
+func (q *quorum) String() string {
+	if q.percent {
+		return fmt.Sprintf("%v%%", q.min)
+	}
+	return fmt.Sprintf("%v", q.min)
+}
+
+// withQuorum returns a copy of feedData set as down or degraded if the instances fetched don't meet the quorum.
+// A degraded feed is kept up, but its weight is reduced in proportion to the instances fetched. It's set as down
+// if it has no weight.
+func withQuorum(feed string, feedData *internal.FeedData, q *quorum, fetched, total int) *internal.FeedData {
+	if q.met(fetched, total) {
+		return feedData
+	}
+
+	data := *feedData
+	action := q.action
+	if action == belowQuorumDegraded && data.Weight != nil && total > 0 {
+		weight := *data.Weight * float64(fetched) / float64(total)
+		data.Weight = &weight
+	} else {
+		action = belowQuorumDown
+		data.Up = false
+	}
+
+	log.Printf("feed %v is %v: %d of %d NGINX instances were fetched, the min is %v", feed, action, fetched, total, q)
+	return &data
+}
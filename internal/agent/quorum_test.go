@@ -0,0 +1,168 @@
+package agent
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+	"github.com/nginxinc/nginx-ns1-gslb/internal/output"
+)
+
+func TestNewQuorum(t *testing.T) {
+	testCases := []struct {
+		feed     output.Feed
+		expected *quorum
+		wantErr  bool
+		msg      string
+	}{
+		{
+			feed:     output.Feed{},
+			expected: nil,
+			msg:      "no quorum",
+		},
+		{
+			feed:     output.Feed{MinHealthyInstances: "3"},
+			expected: &quorum{min: 3, action: belowQuorumDown},
+			msg:      "number of instances",
+		},
+		{
+			feed:     output.Feed{MinHealthyInstances: "75%", BelowQuorum: belowQuorumDegraded},
+			expected: &quorum{min: 75, percent: true, action: belowQuorumDegraded},
+			msg:      "percentage of instances",
+		},
+		{
+			feed:    output.Feed{MinHealthyInstances: "1.5"},
+			wantErr: true,
+			msg:     "number of instances not an integer",
+		},
+		{
+			feed:    output.Feed{MinHealthyInstances: "150%"},
+			wantErr: true,
+			msg:     "percentage out of range",
+		},
+		{
+			feed:    output.Feed{MinHealthyInstances: "2", BelowQuorum: "ignore"},
+			wantErr: true,
+			msg:     "wrong action",
+		},
+	}
+
+	for _, testCase := range testCases {
+		q, err := newQuorum(&testCase.feed)
+		if err == nil && testCase.wantErr {
+			t.Errorf("newQuorum err returned <nil>, but an error was expected for case: %v", testCase.msg)
+		}
+		if err != nil && !testCase.wantErr {
+			t.Errorf("newQuorum returned an err: %v for case: %v", err, testCase.msg)
+		}
+		if !reflect.DeepEqual(q, testCase.expected) {
+			t.Errorf("newQuorum returned %+v, but %+v expected for case: %v", q, testCase.expected, testCase.msg)
+		}
+	}
+}
+
+func TestQuorumCfgErrors(t *testing.T) {
+	testCases := []struct {
+		feed    output.Feed
+		wantErr bool
+		msg     string
+	}{
+		{
+			feed:    output.Feed{MinHealthyInstances: "2", BelowQuorum: belowQuorumDegraded, Metadata: &output.FeedMetadata{Weight: connectionsStat}},
+			wantErr: false,
+			msg:     "degraded with weight",
+		},
+		{
+			feed:    output.Feed{MinHealthyInstances: "2", BelowQuorum: belowQuorumDegraded},
+			wantErr: true,
+			msg:     "degraded without weight",
+		},
+		{
+			feed:    output.Feed{BelowQuorum: belowQuorumDown},
+			wantErr: true,
+			msg:     "below_quorum without min_healthy_instances",
+		},
+	}
+
+	for _, testCase := range testCases {
+		errs := quorumCfgErrors(&testCase.feed)
+		if len(errs) == 0 && testCase.wantErr {
+			t.Errorf("quorumCfgErrors returned no errors, but an error was expected for case: %v", testCase.msg)
+		}
+		if len(errs) > 0 && !testCase.wantErr {
+			t.Errorf("quorumCfgErrors returned %v for case: %v", errs, testCase.msg)
+		}
+	}
+}
+
+func TestWithQuorum(t *testing.T) {
+	weight, halfWeight := 4.0, 2.0
+	testCases := []struct {
+		quorum   *quorum
+		fetched  int
+		weight   *float64
+		expected *internal.FeedData
+		msg      string
+	}{
+		{
+			quorum:   &quorum{min: 2, action: belowQuorumDown},
+			fetched:  2,
+			weight:   &weight,
+			expected: &internal.FeedData{Connections: 10, Up: true, Weight: &weight},
+			msg:      "quorum met",
+		},
+		{
+			quorum:   &quorum{min: 3, action: belowQuorumDown},
+			fetched:  2,
+			weight:   &weight,
+			expected: &internal.FeedData{Connections: 10, Up: false, Weight: &weight},
+			msg:      "published as down under the quorum",
+		},
+		{
+			quorum:   &quorum{min: 75, percent: true, action: belowQuorumDegraded},
+			fetched:  2,
+			weight:   &weight,
+			expected: &internal.FeedData{Connections: 10, Up: true, Weight: &halfWeight},
+			msg:      "published as degraded under the quorum",
+		},
+		{
+			quorum:   &quorum{min: 75, percent: true, action: belowQuorumDegraded},
+			fetched:  1,
+			expected: &internal.FeedData{Connections: 10, Up: false},
+			msg:      "published as down under the quorum when degraded without weight",
+		},
+	}
+
+	for _, testCase := range testCases {
+		feedData := &internal.FeedData{Connections: 10, Up: true, Weight: testCase.weight}
+		data := withQuorum("feed01", feedData, testCase.quorum, testCase.fetched, 4)
+		if !reflect.DeepEqual(data, testCase.expected) {
+			t.Errorf("withQuorum returned %+v, but %+v expected for case: %v", data, testCase.expected, testCase.msg)
+		}
+	}
+}
+
+func TestRunOnceQuorum(t *testing.T) {
+	pusher := &fakePusher{}
+	agent := &Agent{
+		cfg:     &Cfg{Interval: 60},
+		fetcher: &fakeFetcher{stats: createExampleStatsSlice(1, false), instances: 4},
+		pusher:  pusher,
+		sources: newFeedSources([]output.Feed{
+			{FeedName: "feed01", Method: globalMethod, MinHealthyInstances: "50%"},
+			{FeedName: "feed02", Method: globalMethod},
+		}),
+	}
+
+	if err := agent.RunOnce(context.Background()); err != nil {
+		t.Errorf("RunOnce returned an err: %v", err)
+	}
+	expected := map[string]*internal.FeedData{
+		"feed01": {Up: false},
+		"feed02": {Up: true},
+	}
+	if !reflect.DeepEqual(pusher.pushed, expected) {
+		t.Errorf("RunOnce pushed %v, but %v expected when 1 of 4 instances was fetched", pusher.pushed, expected)
+	}
+}
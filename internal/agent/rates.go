@@ -0,0 +1,161 @@
+package agent
+
+import (
+	"math"
+	"time"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+)
+
+// Sampling types calculated from the difference of the counters between cycles
+const (
+	mergeRequestsRate = "requests_rate"
+	mergeErrorsRatio  = "errors_ratio"
+	mergeBytesRate    = "bytes_rate"
+)
+
+// counters are the cumulative stats of an NGINX resource in a single instance
+type counters struct {
+	requests     uint64
+	responses5xx uint64
+	bytes        uint64
+	at           time.Time
+}
+
+// isRateSampling returns true if the sampling type is calculated from the counters of the previous cycle
+func isRateSampling(samplingType string) bool {
+	switch samplingType {
+	case mergeRequestsRate, mergeErrorsRatio, mergeBytesRate:
+		return true
+	}
+	return false
+}
+
+// rateSamplingAvailable returns true if the stats needed by the sampling type are available for the method and protocol
+func rateSamplingAvailable(samplingType, method, protocol string) bool {
+	switch {
+	case method == globalMethod:
+		// Only the total of requests is available for the whole instance
+		return samplingType == mergeRequestsRate
+	case method == upstreamGroupsMethod && protocol == streamProtocol:
+		// The peers of the stream upstreams have no responses
+		return samplingType != mergeErrorsRatio
+	}
+	return true
+}
+
+// resourceCounters returns the counters of the NGINX resource name in the stats of an instance
+func resourceCounters(s *internal.Stats, method, name string) (counters, bool) {
+	switch method {
+	case globalMethod:
+		return counters{requests: s.Requests}, true
+	case upstreamGroupsMethod:
+		ups, ok := s.Upstreams[name]
+		if !ok {
+			return counters{}, false
+		}
+		// The peers are added up, whatever their state, so the counters don't go back when a peer goes down
+		var c counters
+		for _, p := range ups.Peers {
+			c.requests += p.Requests
+			c.responses5xx += p.Responses5xx
+			c.bytes += p.Sent + p.Received
+		}
+		return c, true
+	case statusZonesMethod:
+		zone, ok := s.ServerZones[name]
+		if !ok {
+			return counters{}, false
+		}
+		return counters{requests: zone.Requests, responses5xx: zone.Responses5xx, bytes: zone.Sent + zone.Received}, true
+	}
+	return counters{}, false
+}
+
+// counterDelta is the difference of the counters of a resource in an instance with the previous cycle
+type counterDelta struct {
+	counters
+	// elapsed is the time in seconds since the previous cycle
+	elapsed float64
+}
+
+// counterDeltas returns the difference of the counters of the NGINX resource name with the ones of the previous cycle
+// for every instance, and the counters of this cycle by instance. The instances without counters of a previous cycle
+// or whose counters were reset are skipped.
+func counterDeltas(statsSlice []*internal.Stats, method, name string, previous map[string]counters, now time.Time) ([]counterDelta, map[string]counters) {
+	var deltas []counterDelta
+	current := make(map[string]counters)
+	for _, s := range statsSlice {
+		c, ok := resourceCounters(s, method, name)
+		if !ok {
+			continue
+		}
+		c.at = now
+		current[s.Instance] = c
+
+		p, ok := previous[s.Instance]
+		elapsed := now.Sub(p.at).Seconds()
+		// The counters are reset when NGINX is restarted
+		if !ok || elapsed <= 0 || c.requests < p.requests || c.responses5xx < p.responses5xx || c.bytes < p.bytes {
+			continue
+		}
+
+		deltas = append(deltas, counterDelta{
+			counters: counters{
+				requests:     c.requests - p.requests,
+				responses5xx: c.responses5xx - p.responses5xx,
+				bytes:        c.bytes - p.bytes,
+			},
+			elapsed: elapsed,
+		})
+	}
+	return deltas, current
+}
+
+// errorsRatio returns the percentage of responses with a 5xx status, or false if there were no requests
+func errorsRatio(deltas []counterDelta) (float64, bool) {
+	var requests, responses5xx uint64
+	for _, d := range deltas {
+		requests += d.requests
+		responses5xx += d.responses5xx
+	}
+	if requests == 0 {
+		return 0, false
+	}
+	return float64(responses5xx) / float64(requests) * 100, true
+}
+
+// requestsRate returns the requests per second added up for all the instances
+func requestsRate(deltas []counterDelta) float64 {
+	var rate float64
+	for _, d := range deltas {
+		rate += float64(d.requests) / d.elapsed
+	}
+	return rate
+}
+
+// withRates replaces the connections of the data with the value of the rate sampling type, calculated from the
+// counters of the previous cycle of every instance. The counters of this cycle are kept for the next one.
+// The connections are 0 (not published) if none of the instances has counters of a previous cycle.
+func (source *feedSource) withRates(data map[string]*internal.FeedData, statsSlice []*internal.Stats, now time.Time) {
+	current := make(map[string]map[string]counters, len(data))
+	for name, feedData := range data {
+		var deltas []counterDelta
+		deltas, current[name] = counterDeltas(statsSlice, source.method, name, source.counters[name], now)
+
+		var rate float64
+		switch source.samplingType {
+		case mergeRequestsRate:
+			rate = requestsRate(deltas)
+		case mergeBytesRate:
+			for _, d := range deltas {
+				rate += float64(d.bytes) / d.elapsed
+			}
+		case mergeErrorsRatio:
+			// The ratio is published as a percentage, as the connections are an integer
+			rate, _ = errorsRatio(deltas)
+		}
+		feedData.Connections = uint64(math.Round(rate))
+	}
+	source.counters = current
+}
@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+)
+
+// createCountersStats returns the stats of an instance with the upstream and server zone backend
+func createCountersStats(instance string, requests, responses5xx, bytes uint64) *internal.Stats {
+	return &internal.Stats{
+		Instance: instance,
+		Requests: requests,
+		Upstreams: map[string]internal.Upstream{
+			"backend": {Peers: []internal.Peer{
+				{State: peerUpState, Requests: requests / 2, Responses5xx: responses5xx, Sent: bytes},
+				{State: "down", Requests: requests / 2},
+			}},
+		},
+		ServerZones: map[string]internal.ServerZone{
+			"backend": {Requests: requests, Responses5xx: responses5xx, Received: bytes},
+		},
+	}
+}
+
+func TestWithRates(t *testing.T) {
+	start := time.Now()
+	testCases := []struct {
+		method       string
+		samplingType string
+		// cycles are the stats of every cycle, fetched every 10 seconds
+		cycles   [][]*internal.Stats
+		expected uint64
+		msg      string
+	}{
+		{
+			method:       upstreamGroupsMethod,
+			samplingType: mergeRequestsRate,
+			cycles: [][]*internal.Stats{
+				{createCountersStats("host1", 100, 0, 0)},
+			},
+			expected: 0,
+			msg:      "no previous cycle",
+		},
+		{
+			method:       upstreamGroupsMethod,
+			samplingType: mergeRequestsRate,
+			cycles: [][]*internal.Stats{
+				{createCountersStats("host1", 100, 0, 0), createCountersStats("host2", 1000, 0, 0)},
+				{createCountersStats("host1", 300, 0, 0), createCountersStats("host2", 1500, 0, 0)},
+			},
+			expected: 70,
+			msg:      "requests rate of all the instances",
+		},
+		{
+			method:       statusZonesMethod,
+			samplingType: mergeRequestsRate,
+			cycles: [][]*internal.Stats{
+				{createCountersStats("host1", 100, 0, 0), createCountersStats("host2", 1000, 0, 0)},
+				{createCountersStats("host1", 300, 0, 0), createCountersStats("host2", 50, 0, 0)},
+			},
+			expected: 20,
+			msg:      "instance with the counters reset is skipped",
+		},
+		{
+			method:       globalMethod,
+			samplingType: mergeRequestsRate,
+			cycles: [][]*internal.Stats{
+				{createCountersStats("host1", 100, 0, 0)},
+				{createCountersStats("host1", 300, 0, 0), createCountersStats("host2", 1000, 0, 0)},
+			},
+			expected: 20,
+			msg:      "new instance without a previous cycle is skipped",
+		},
+		{
+			method:       statusZonesMethod,
+			samplingType: mergeErrorsRatio,
+			cycles: [][]*internal.Stats{
+				{createCountersStats("host1", 100, 10, 0), createCountersStats("host2", 100, 0, 0)},
+				{createCountersStats("host1", 200, 20, 0), createCountersStats("host2", 300, 20, 0)},
+			},
+			expected: 10,
+			msg:      "errors ratio of all the instances",
+		},
+		{
+			method:       upstreamGroupsMethod,
+			samplingType: mergeBytesRate,
+			cycles: [][]*internal.Stats{
+				{createCountersStats("host1", 0, 0, 1000)},
+				{createCountersStats("host1", 0, 0, 6000)},
+			},
+			expected: 500,
+			msg:      "bytes rate",
+		},
+	}
+
+	for _, testCase := range testCases {
+		source := createFeedSource(testCase.method, testCase.samplingType, 0)
+		var data map[string]*internal.FeedData
+		for i, statsSlice := range testCase.cycles {
+			data = map[string]*internal.FeedData{"backend": {Up: true}, globalMethod: {Up: true}}
+			source.withRates(data, statsSlice, start.Add(time.Duration(i)*10*time.Second))
+		}
+
+		name := "backend"
+		if testCase.method == globalMethod {
+			name = globalMethod
+		}
+		if data[name].Connections != testCase.expected {
+			t.Errorf("withRates returned %v connections, but %v expected for case: %v", data[name].Connections, testCase.expected, testCase.msg)
+		}
+	}
+}
+
+func TestRateSamplingAvailable(t *testing.T) {
+	testCases := []struct {
+		samplingType string
+		method       string
+		protocol     string
+		expected     bool
+	}{
+		{samplingType: mergeRequestsRate, method: globalMethod, expected: true},
+		{samplingType: mergeBytesRate, method: globalMethod, expected: false},
+		{samplingType: mergeErrorsRatio, method: upstreamGroupsMethod, protocol: httpProtocol, expected: true},
+		{samplingType: mergeErrorsRatio, method: upstreamGroupsMethod, protocol: streamProtocol, expected: false},
+		{samplingType: mergeErrorsRatio, method: statusZonesMethod, protocol: streamProtocol, expected: true},
+	}
+
+	for _, testCase := range testCases {
+		available := rateSamplingAvailable(testCase.samplingType, testCase.method, testCase.protocol)
+		if available != testCase.expected {
+			t.Errorf("rateSamplingAvailable returned %v, but %v expected for %v with method %v and protocol %v",
+				available, testCase.expected, testCase.samplingType, testCase.method, testCase.protocol)
+		}
+	}
+}
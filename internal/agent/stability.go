@@ -0,0 +1,166 @@
+package agent
+
+import (
+	"log"
+	"time"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+)
+
+// HoldDownCfg stores for how long the last data is published when none of the NGINX instances can be fetched.
+// The feeds are published as down once any of the limits is reached. 0 disables the limit.
+type HoldDownCfg struct {
+	Cycles uint32 `yaml:"cycles"`
+	// Time is the max time in seconds since the last successful fetch
+	Time uint32 `yaml:"time"`
+}
+
+// HysteresisCfg stores the number of consecutive cycles a feed must be observed up or down to change its published state
+type HysteresisCfg struct {
+	Up   uint32 `yaml:"up"`
+	Down uint32 `yaml:"down"`
+}
+
+// stabilizer applies the hold-down and the hysteresis to the data of the feeds before it's published
+type stabilizer struct {
+	holdDown   HoldDownCfg
+	hysteresis HysteresisCfg
+
+	// lastData is the data published after the last successful fetch
+	lastData     map[string]*internal.FeedData
+	lastFetch    time.Time
+	failedCycles uint32
+	feeds        map[string]*feedState
+}
+
+// feedState is the published up state of a feed and the number of consecutive cycles it was observed in the opposite one
+type feedState struct {
+	up      bool
+	pending uint32
+}
+
+// newStabilizer returns a stabilizer, or nil if neither the hold-down nor the hysteresis are enabled
+func newStabilizer(cfg *Cfg) *stabilizer {
+	if cfg.HoldDown == (HoldDownCfg{}) && cfg.Hysteresis.Up <= 1 && cfg.Hysteresis.Down <= 1 {
+		return nil
+	}
+	return &stabilizer{
+		holdDown:   cfg.HoldDown,
+		hysteresis: cfg.Hysteresis,
+		feeds:      make(map[string]*feedState),
+	}
+}
+
+// fetched records the data of a successful fetch and returns the data to publish with the hysteresis applied
+func (s *stabilizer) fetched(data map[string]*internal.FeedData, now time.Time) map[string]*internal.FeedData {
+	if s == nil {
+		return data
+	}
+
+	data = s.applyHysteresis(data)
+	s.lastData = data
+	s.lastFetch = now
+	s.failedCycles = 0
+	return data
+}
+
+// failed returns the data to publish when none of the NGINX instances could be fetched: the data of the last successful
+// fetch while the hold-down lasts, and down otherwise (with the hysteresis applied).
+func (s *stabilizer) failed(down map[string]*internal.FeedData, now time.Time) map[string]*internal.FeedData {
+	if s == nil {
+		return down
+	}
+
+	s.failedCycles++
+	if s.holding(now) {
+		log.Printf("Publishing the data of the last successful fetch (%d failed cycles since %v)", s.failedCycles, s.lastFetch.Format(time.RFC3339))
+		// The feeds added since the last successful fetch are published as down
+		data := make(map[string]*internal.FeedData, len(down))
+		for feed, feedData := range down {
+			if last, ok := s.lastData[feed]; ok {
+				feedData = last
+			}
+			data[feed] = feedData
+		}
+		return data
+	}
+	return s.applyHysteresis(down)
+}
+
+// keep copies the hold-down data and the hysteresis state of the feeds from the stabilizer of the previous configuration
+func (s *stabilizer) keep(previous *stabilizer, feeds map[string]bool) {
+	if s == nil || previous == nil {
+		return
+	}
+
+	for feed, state := range previous.feeds {
+		if feeds[feed] {
+			kept := *state
+			s.feeds[feed] = &kept
+		}
+	}
+	if previous.lastData == nil {
+		return
+	}
+	s.lastData = make(map[string]*internal.FeedData)
+	for feed, feedData := range previous.lastData {
+		if feeds[feed] {
+			s.lastData[feed] = feedData
+		}
+	}
+	s.lastFetch = previous.lastFetch
+	s.failedCycles = previous.failedCycles
+}
+
+// holding returns true if the last data can still be published
+func (s *stabilizer) holding(now time.Time) bool {
+	if s.lastData == nil || s.holdDown == (HoldDownCfg{}) {
+		return false
+	}
+	if s.holdDown.Cycles > 0 && s.failedCycles > s.holdDown.Cycles {
+		return false
+	}
+	if s.holdDown.Time > 0 && now.Sub(s.lastFetch) > time.Duration(s.holdDown.Time)*time.Second {
+		return false
+	}
+	return true
+}
+
+// applyHysteresis returns the data with the up state of every feed only changed once it was observed
+// for the configured number of consecutive cycles. The rest of the values are not changed.
+func (s *stabilizer) applyHysteresis(data map[string]*internal.FeedData) map[string]*internal.FeedData {
+	result := make(map[string]*internal.FeedData, len(data))
+	for feed, feedData := range data {
+		state, ok := s.feeds[feed]
+		if !ok {
+			// The first state observed is published as it is
+			s.feeds[feed] = &feedState{up: feedData.Up}
+			result[feed] = feedData
+			continue
+		}
+
+		if feedData.Up == state.up {
+			state.pending = 0
+			result[feed] = feedData
+			continue
+		}
+
+		required := s.hysteresis.Down
+		if feedData.Up {
+			required = s.hysteresis.Up
+		}
+		state.pending++
+		if state.pending >= required {
+			state.up = feedData.Up
+			state.pending = 0
+			result[feed] = feedData
+			continue
+		}
+
+		log.Printf("feed %v observed as up=%v for %d of %d cycles, still published as up=%v", feed, feedData.Up, state.pending, required, state.up)
+		held := *feedData
+		held.Up = state.up
+		result[feed] = &held
+	}
+	return result
+}
@@ -0,0 +1,102 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+)
+
+func TestNewStabilizer(t *testing.T) {
+	if s := newStabilizer(&Cfg{Hysteresis: HysteresisCfg{Up: 1, Down: 1}}); s != nil {
+		t.Errorf("newStabilizer returned %+v, but <nil> expected when the hold-down and the hysteresis are disabled", s)
+	}
+	if s := newStabilizer(&Cfg{HoldDown: HoldDownCfg{Cycles: 2}}); s == nil {
+		t.Errorf("newStabilizer returned <nil>, but a stabilizer expected when the hold-down is enabled")
+	}
+}
+
+func TestStabilizerHoldDown(t *testing.T) {
+	start := time.Now()
+	testCases := []struct {
+		holdDown HoldDownCfg
+		// failures are the times of the failed cycles since the last successful fetch
+		failures   []time.Duration
+		expectedUp bool
+		msg        string
+	}{
+		{
+			holdDown:   HoldDownCfg{Cycles: 2},
+			failures:   []time.Duration{time.Minute, 2 * time.Minute},
+			expectedUp: true,
+			msg:        "failed cycles within the hold-down",
+		},
+		{
+			holdDown:   HoldDownCfg{Cycles: 2},
+			failures:   []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute},
+			expectedUp: false,
+			msg:        "failed cycles over the hold-down",
+		},
+		{
+			holdDown:   HoldDownCfg{Time: 90},
+			failures:   []time.Duration{time.Minute},
+			expectedUp: true,
+			msg:        "time within the hold-down",
+		},
+		{
+			holdDown:   HoldDownCfg{Cycles: 5, Time: 90},
+			failures:   []time.Duration{time.Minute, 2 * time.Minute},
+			expectedUp: false,
+			msg:        "time over the hold-down",
+		},
+	}
+
+	for _, testCase := range testCases {
+		s := newStabilizer(&Cfg{HoldDown: testCase.holdDown})
+		s.fetched(map[string]*internal.FeedData{"feed01": {Connections: 5, Up: true}}, start)
+
+		var data map[string]*internal.FeedData
+		for _, failure := range testCase.failures {
+			data = s.failed(map[string]*internal.FeedData{"feed01": {Up: false}}, start.Add(failure))
+		}
+		if data["feed01"].Up != testCase.expectedUp {
+			t.Errorf("stabilizer.failed returned up=%v, but %v expected for case: %v", data["feed01"].Up, testCase.expectedUp, testCase.msg)
+		}
+	}
+}
+
+func TestStabilizerHysteresis(t *testing.T) {
+	s := newStabilizer(&Cfg{Hysteresis: HysteresisCfg{Up: 3, Down: 2}})
+	now := time.Now()
+	cycles := []struct {
+		observed bool
+		expected bool
+	}{
+		{observed: true, expected: true},
+		{observed: false, expected: true},
+		{observed: true, expected: true},
+		{observed: false, expected: true},
+		{observed: false, expected: false},
+		{observed: true, expected: false},
+		{observed: true, expected: false},
+		{observed: true, expected: true},
+	}
+
+	for i, cycle := range cycles {
+		data := s.fetched(map[string]*internal.FeedData{"feed01": {Connections: uint64(i), Up: cycle.observed}}, now)
+		if data["feed01"].Up != cycle.expected {
+			t.Errorf("stabilizer.fetched returned up=%v, but %v expected for cycle %d", data["feed01"].Up, cycle.expected, i)
+		}
+		if data["feed01"].Connections != uint64(i) {
+			t.Errorf("stabilizer.fetched returned %v connections, but %v expected for cycle %d", data["feed01"].Connections, i, i)
+		}
+	}
+}
+
+func TestStabilizerNil(t *testing.T) {
+	var s *stabilizer
+	down := map[string]*internal.FeedData{"feed01": {Up: false}}
+	if data := s.failed(down, time.Now()); data["feed01"].Up {
+		t.Errorf("stabilizer.failed returned the feed as up, but down expected when it's disabled")
+	}
+}
@@ -4,4 +4,54 @@ package internal
 type FeedData struct {
 	Connections uint64 `json:"connections,omitempty"`
 	Up          bool   `json:"up"`
+	// Weight, LoadAvg, HighWatermark and LowWatermark are only published when they are configured for the feed
+	Weight        *float64 `json:"weight,omitempty"`
+	LoadAvg       *float64 `json:"loadavg,omitempty"`
+	HighWatermark *float64 `json:"high_watermark,omitempty"`
+	LowWatermark  *float64 `json:"low_watermark,omitempty"`
+}
+
+// Stats are the statistics of a single NGINX instance, normalised so they don't depend on the source they were fetched from
+type Stats struct {
+	// Instance is the address of the NGINX instance the stats were fetched from
+	Instance string
+	// Connections is the number of active client connections
+	Connections uint64
+	// Requests is the total number of HTTP client requests
+	Requests    uint64
+	Upstreams   map[string]Upstream
+	ServerZones map[string]ServerZone
+	// StreamUpstreams and StreamServerZones are the stats of the TCP/UDP (stream) upstreams and server zones
+	StreamUpstreams   map[string]Upstream
+	StreamServerZones map[string]ServerZone
+}
+
+// Upstream represents the stats of an upstream group
+type Upstream struct {
+	Peers []Peer
+}
+
+// Peer represents the stats of a server of an upstream group
+type Peer struct {
+	State  string
+	Active uint64
+	// Requests, Responses5xx, Sent and Received are cumulative counters.
+	// For stream upstreams, Requests is the number of connections and there are no responses.
+	Requests     uint64
+	Responses5xx uint64
+	Sent         uint64
+	Received     uint64
+	// ResponseTime is the average time in milliseconds to get the full response from the server
+	ResponseTime uint64
+}
+
+// ServerZone represents the stats of a status zone (HTTP or stream)
+type ServerZone struct {
+	Processing uint64
+	// Requests, Responses5xx, Sent and Received are cumulative counters.
+	// For stream server zones, Requests is the number of connections and Responses5xx the sessions completed with 5xx.
+	Requests     uint64
+	Responses5xx uint64
+	Sent         uint64
+	Received     uint64
 }
@@ -0,0 +1,86 @@
+package input
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	secretEnvPrefix  = "env:"
+	secretFilePrefix = "file:"
+)
+
+// AuthCfg stores the credentials used to access the NGINX Plus API.
+// password, bearer_token and the values of headers can be loaded from an environment variable
+// using the env:VARIABLE format or from a file using the file:/path/to/file format.
+type AuthCfg struct {
+	Username    string            `yaml:"username"`
+	Password    string            `yaml:"password"`
+	BearerToken string            `yaml:"bearer_token"`
+	Headers     map[string]string `yaml:"headers"`
+}
+
+// headers returns the http headers that need to be added to every request to the NGINX Plus API
+func (ac AuthCfg) headers() (http.Header, error) {
+	headers := make(http.Header)
+
+	for name, value := range ac.Headers {
+		v, err := resolveSecret(value)
+		if err != nil {
+			return nil, fmt.Errorf("error reading header %v: %w", name, err)
+		}
+		headers.Set(name, v)
+	}
+
+	if ac.Password != "" && ac.Username == "" {
+		return nil, fmt.Errorf("a username is required to use basic authentication")
+	}
+
+	if ac.Username != "" && ac.BearerToken != "" {
+		return nil, fmt.Errorf("basic authentication and bearer token can't be used at the same time")
+	}
+
+	if ac.Username != "" {
+		password, err := resolveSecret(ac.Password)
+		if err != nil {
+			return nil, fmt.Errorf("error reading password: %w", err)
+		}
+		credentials := base64.StdEncoding.EncodeToString([]byte(ac.Username + ":" + password))
+		headers.Set("Authorization", "Basic "+credentials)
+	}
+
+	if ac.BearerToken != "" {
+		token, err := resolveSecret(ac.BearerToken)
+		if err != nil {
+			return nil, fmt.Errorf("error reading bearer token: %w", err)
+		}
+		headers.Set("Authorization", "Bearer "+token)
+	}
+
+	return headers, nil
+}
+
+// resolveSecret returns the value of a secret that can be set in plain text, read from an environment variable (env:VARIABLE)
+// or read from a file (file:/path/to/file)
+func resolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, secretEnvPrefix):
+		name := strings.TrimPrefix(value, secretEnvPrefix)
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %v is not defined", name)
+		}
+		return v, nil
+	case strings.HasPrefix(value, secretFilePrefix):
+		path := strings.TrimPrefix(value, secretFilePrefix)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("error reading file at %v: %w", path, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	}
+	return value, nil
+}
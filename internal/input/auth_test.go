@@ -0,0 +1,166 @@
+package input
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatalf("error writing the secret file: %v", err)
+	}
+	t.Setenv("NGINX_NS1_GSLB_TEST_SECRET", "from-env")
+
+	testCases := []struct {
+		value    string
+		expected string
+		wantErr  bool
+		msg      string
+	}{
+		{
+			value:    "plain",
+			expected: "plain",
+			msg:      "plain text value",
+		},
+		{
+			value:    "env:NGINX_NS1_GSLB_TEST_SECRET",
+			expected: "from-env",
+			msg:      "value from an environment variable",
+		},
+		{
+			value:    "file:" + secretFile,
+			expected: "from-file",
+			msg:      "value from a file",
+		},
+		{
+			value:   "env:NGINX_NS1_GSLB_TEST_UNDEFINED",
+			wantErr: true,
+			msg:     "undefined environment variable",
+		},
+		{
+			value:   "file:" + filepath.Join(t.TempDir(), "missing"),
+			wantErr: true,
+			msg:     "missing file",
+		},
+	}
+
+	for _, testCase := range testCases {
+		value, err := resolveSecret(testCase.value)
+		if err == nil && testCase.wantErr {
+			t.Errorf("resolveSecret err returned <nil>, but an error was expected for case: %v", testCase.msg)
+		}
+		if err != nil && !testCase.wantErr {
+			t.Errorf("resolveSecret returned an err: %v for case: %v", err, testCase.msg)
+		}
+		if value != testCase.expected {
+			t.Errorf("resolveSecret returned %v, but %v expected for case: %v", value, testCase.expected, testCase.msg)
+		}
+	}
+}
+
+func TestAuthHeaders(t *testing.T) {
+	testCases := []struct {
+		auth     AuthCfg
+		expected http.Header
+		wantErr  bool
+		msg      string
+	}{
+		{
+			auth:     AuthCfg{},
+			expected: http.Header{},
+			msg:      "no credentials",
+		},
+		{
+			auth:     AuthCfg{Username: "user", Password: "pass"},
+			expected: http.Header{"Authorization": {"Basic dXNlcjpwYXNz"}},
+			msg:      "basic authentication",
+		},
+		{
+			auth:     AuthCfg{BearerToken: "token", Headers: map[string]string{"x-api-client": "gslb"}},
+			expected: http.Header{"Authorization": {"Bearer token"}, "X-Api-Client": {"gslb"}},
+			msg:      "bearer token and custom headers",
+		},
+		{
+			auth:    AuthCfg{Username: "user", BearerToken: "token"},
+			wantErr: true,
+			msg:     "basic authentication and bearer token",
+		},
+		{
+			auth:    AuthCfg{Password: "pass"},
+			wantErr: true,
+			msg:     "password without username",
+		},
+	}
+
+	for _, testCase := range testCases {
+		headers, err := testCase.auth.headers()
+		if err == nil && testCase.wantErr {
+			t.Errorf("headers err returned <nil>, but an error was expected for case: %v", testCase.msg)
+		}
+		if err != nil && !testCase.wantErr {
+			t.Errorf("headers returned an err: %v for case: %v", err, testCase.msg)
+		}
+		if !testCase.wantErr && !reflect.DeepEqual(headers, testCase.expected) {
+			t.Errorf("headers returned %v, but %v expected for case: %v", headers, testCase.expected, testCase.msg)
+		}
+	}
+}
+
+func TestConfigureNginxPlusAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("[4,5,6,7,8]"))
+	}))
+	defer server.Close()
+
+	host := NginxHost{Host: server.Listener.Addr().String()}
+
+	nginxPlus := NginxPlus{}
+	err := nginxPlus.Configure(&Cfg{Hosts: []NginxHost{host}, ClientTimeout: 1})
+	if err == nil {
+		t.Errorf("NGINX Plus configuration err returned <nil>, but an error was expected because no credentials are configured")
+	}
+
+	host.Auth = &AuthCfg{Username: "user", Password: "pass"}
+	err = nginxPlus.Configure(&Cfg{Hosts: []NginxHost{host}, ClientTimeout: 1})
+	if err != nil {
+		t.Errorf("NGINX Plus configuration returned an err: %v using basic authentication", err)
+	}
+}
+
+func TestAPITransportRoundTrip(t *testing.T) {
+	var host, authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		authorization = r.Header.Get("Authorization")
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("error creating the request: %v", err)
+	}
+	originalHost := req.Host
+
+	transport := newAPITransport("nginx.example.com", http.Header{"Authorization": {"Bearer token"}})
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip returned an err: %v", err)
+	}
+	resp.Body.Close()
+
+	if host != "nginx.example.com" || authorization != "Bearer token" {
+		t.Errorf("RoundTrip sent the Host %v and the Authorization %v, but nginx.example.com and Bearer token expected", host, authorization)
+	}
+	if req.Host != originalHost || req.Header.Get("Authorization") != "" {
+		t.Errorf("RoundTrip modified the original request to the Host %v and the headers %v", req.Host, req.Header)
+	}
+}
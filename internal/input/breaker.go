@@ -0,0 +1,79 @@
+package input
+
+import (
+	"errors"
+	"time"
+)
+
+// States of the circuit breaker of an instance
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+var errBreakerOpen = errors.New("circuit breaker is open, the host is skipped")
+
+// BreakerCfg stores the parameters of the circuit breaker used for every NGINX host
+type BreakerCfg struct {
+	// FailureThreshold is the number of consecutive failed cycles after which the host is skipped. 0 disables the breaker
+	FailureThreshold int `yaml:"failure_threshold"`
+	// OpenTimeout is the time in seconds a host is skipped before it is probed again
+	OpenTimeout int `yaml:"open_timeout"`
+}
+
+type breakerState int
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// breaker skips a host after a number of consecutive failures. Once the open timeout expires, the host is probed
+// again (half-open): if the probe succeeds the breaker is closed, otherwise it's opened again.
+type breaker struct {
+	threshold   int
+	openTimeout time.Duration
+
+	state    breakerState
+	failures int
+	openedAt time.Time
+}
+
+func newBreaker(cfg *BreakerCfg) *breaker {
+	return &breaker{
+		threshold:   cfg.FailureThreshold,
+		openTimeout: time.Duration(cfg.OpenTimeout) * time.Second,
+	}
+}
+
+// allow returns true if the host can be fetched. An open breaker changes to half-open once the timeout expires.
+func (b *breaker) allow(now time.Time) bool {
+	if b.state == breakerOpen {
+		if now.Sub(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.state = breakerHalfOpen
+	}
+	return true
+}
+
+// success records a successful fetch, closing the breaker
+func (b *breaker) success() {
+	b.state = breakerClosed
+	b.failures = 0
+}
+
+// failure records a failed fetch, opening the breaker if the threshold is reached or the probe failed
+func (b *breaker) failure(now time.Time) {
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = now
+	}
+}
@@ -0,0 +1,173 @@
+package input
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+)
+
+func TestBreaker(t *testing.T) {
+	start := time.Now()
+	testCases := []struct {
+		// results of the fetches in order, nil for a success
+		results       []error
+		now           time.Time
+		expectedAllow bool
+		expectedState breakerState
+		msg           string
+	}{
+		{
+			results:       []error{errors.New("error"), errors.New("error")},
+			now:           start,
+			expectedAllow: true,
+			expectedState: breakerClosed,
+			msg:           "failures under the threshold",
+		},
+		{
+			results:       []error{errors.New("error"), errors.New("error"), nil, errors.New("error")},
+			now:           start,
+			expectedAllow: true,
+			expectedState: breakerClosed,
+			msg:           "failures not consecutive",
+		},
+		{
+			results:       []error{errors.New("error"), errors.New("error"), errors.New("error")},
+			now:           start.Add(time.Second),
+			expectedAllow: false,
+			expectedState: breakerOpen,
+			msg:           "threshold reached",
+		},
+		{
+			results:       []error{errors.New("error"), errors.New("error"), errors.New("error")},
+			now:           start.Add(time.Minute),
+			expectedAllow: true,
+			expectedState: breakerHalfOpen,
+			msg:           "open timeout expired",
+		},
+	}
+
+	for _, testCase := range testCases {
+		b := newBreaker(&BreakerCfg{FailureThreshold: 3, OpenTimeout: 60})
+		for _, err := range testCase.results {
+			if err != nil {
+				b.failure(start)
+			} else {
+				b.success()
+			}
+		}
+
+		allow := b.allow(testCase.now)
+		if allow != testCase.expectedAllow {
+			t.Errorf("breaker.allow returned %v, but %v expected for case: %v", allow, testCase.expectedAllow, testCase.msg)
+		}
+		if b.state != testCase.expectedState {
+			t.Errorf("breaker state is %v, but %v expected for case: %v", b.state, testCase.expectedState, testCase.msg)
+		}
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	start := time.Now()
+	b := newBreaker(&BreakerCfg{FailureThreshold: 1, OpenTimeout: 60})
+	b.failure(start)
+
+	b.allow(start.Add(time.Minute))
+	b.failure(start.Add(time.Minute))
+	if b.state != breakerOpen || b.allow(start.Add(90*time.Second)) {
+		t.Errorf("breaker state is %v, but %v expected after a failed probe", b.state, breakerOpen)
+	}
+
+	b.allow(start.Add(2 * time.Minute))
+	b.success()
+	if b.state != breakerClosed {
+		t.Errorf("breaker state is %v, but %v expected after a successful probe", b.state, breakerClosed)
+	}
+}
+
+func TestFetchInstance(t *testing.T) {
+	testCases := []struct {
+		failures         int
+		retries          int
+		backoff          int
+		retryMaxDuration time.Duration
+		breaker          *BreakerCfg
+		expectedAttempts int
+		wantErr          bool
+		msg              string
+	}{
+		{
+			failures:         1,
+			retries:          2,
+			expectedAttempts: 2,
+			wantErr:          false,
+			msg:              "success after a retry",
+		},
+		{
+			failures:         5,
+			retries:          2,
+			expectedAttempts: 3,
+			wantErr:          true,
+			msg:              "retries exhausted",
+		},
+		{
+			failures:         5,
+			retries:          2,
+			backoff:          1,
+			retryMaxDuration: 500 * time.Millisecond,
+			expectedAttempts: 1,
+			wantErr:          true,
+			msg:              "retry after the max duration of the fetch not done",
+		},
+		{
+			failures:         5,
+			retries:          0,
+			breaker:          &BreakerCfg{FailureThreshold: 2, OpenTimeout: 60},
+			expectedAttempts: 2,
+			wantErr:          true,
+			msg:              "host skipped once the breaker is open",
+		},
+	}
+
+	for _, testCase := range testCases {
+		p := &hostsPool{Cfg: &Cfg{FetchRetries: testCase.retries, FetchRetryBackoff: testCase.backoff}}
+		var retryDeadline time.Time
+		if testCase.retryMaxDuration > 0 {
+			retryDeadline = time.Now().Add(testCase.retryMaxDuration)
+		}
+		instance := &Instance{Host: NginxHost{Host: "127.0.0.1", Port: 8080}}
+		if testCase.breaker != nil {
+			instance.breaker = newBreaker(testCase.breaker)
+		}
+
+		attempts := 0
+		fetch := func(_ context.Context, _ *Instance) (*internal.Stats, error) {
+			attempts++
+			if attempts <= testCase.failures {
+				return nil, errors.New("fetch error")
+			}
+			return &internal.Stats{}, nil
+		}
+
+		var err error
+		// The fetch is done in several cycles to check the breaker
+		for cycle := 0; cycle < 3; cycle++ {
+			_, err = p.fetchInstance(context.Background(), instance, retryDeadline, fetch)
+			if testCase.breaker == nil {
+				break
+			}
+		}
+
+		if err == nil && testCase.wantErr {
+			t.Errorf("fetchInstance err returned <nil>, but an error was expected for case: %v", testCase.msg)
+		}
+		if err != nil && !testCase.wantErr {
+			t.Errorf("fetchInstance returned an err: %v for case: %v", err, testCase.msg)
+		}
+		if attempts != testCase.expectedAttempts {
+			t.Errorf("fetchInstance made %v attempts, but %v expected for case: %v", attempts, testCase.expectedAttempts, testCase.msg)
+		}
+	}
+}
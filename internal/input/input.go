@@ -0,0 +1,69 @@
+package input
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+)
+
+// Types of sources
+const (
+	NginxPlusType  = "nginx_plus"
+	StubStatusType = "stub_status"
+)
+
+// Fetcher is implemented by all the sources the agent can get the stats from
+type Fetcher interface {
+	// Fetch gets the stats of all the instances of the source. Instances that can't be fetched are not returned.
+	// If ctx is canceled, Fetch returns without waiting for the pending instances.
+	Fetch(ctx context.Context) []*internal.Stats
+	// Instances returns the number of instances of the source, including the ones that can't be fetched
+	Instances() int
+}
+
+// Cfg stores the configuration parameters for all the NGINX instances to get the data from
+type Cfg struct {
+	Type            string      `yaml:"type"`
+	Hosts           []NginxHost `yaml:"hosts"`
+	ClientTimeout   int         `yaml:"client_timeout"`
+	APIEndpoint     string      `yaml:"api_endpoint"`
+	Resolver        string      `yaml:"resolver"`
+	ResolverTimeout int         `yaml:"resolver_timeout"`
+	ResolveInterval int         `yaml:"resolve_interval"`
+	TLS             TLSCfg      `yaml:"tls"`
+	Auth            AuthCfg     `yaml:"auth"`
+	// FetchRetries is the number of retries in the same cycle when the stats of a host can't be fetched
+	FetchRetries int `yaml:"fetch_retries"`
+	// FetchRetryBackoff is the time in seconds to wait before the first retry, doubled on every retry
+	FetchRetryBackoff int        `yaml:"fetch_retry_backoff"`
+	CircuitBreaker    BreakerCfg `yaml:"circuit_breaker"`
+	// FetchRetryMaxDuration is the max time since the start of the fetch of all the hosts to start a retry.
+	// It's set by the agent, so the retries of a slow host don't delay the cycle. 0 doesn't limit it
+	FetchRetryMaxDuration time.Duration `yaml:"-"`
+}
+
+// Resources are the stats the agent needs from the NGINX instances, so the sources can fetch only those
+type Resources struct {
+	Connections bool
+	// Requests is the total number of HTTP requests, only needed by the rates of the global stats
+	Requests          bool
+	Upstreams         []string
+	ServerZones       []string
+	StreamUpstreams   []string
+	StreamServerZones []string
+}
+
+// New creates and configures the Fetcher for the type of source defined in cfg. The sources fetch only the stats of resources.
+func New(cfg *Cfg, resources *Resources) (Fetcher, error) {
+	switch cfg.Type {
+	case NginxPlusType:
+		nginxPlus := &NginxPlus{resources: resources}
+		return nginxPlus, nginxPlus.Configure(cfg)
+	case StubStatusType:
+		stubStatus := &StubStatus{}
+		return stubStatus, stubStatus.Configure(cfg)
+	}
+	return nil, fmt.Errorf("%v is not a valid source type. Valid types are: %v, %v", cfg.Type, NginxPlusType, StubStatusType)
+}
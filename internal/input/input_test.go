@@ -0,0 +1,12 @@
+package input
+
+import (
+	"testing"
+)
+
+func TestNewWrongType(t *testing.T) {
+	_, err := New(&Cfg{Type: "prometheus", Hosts: []NginxHost{{Host: "localhost"}}}, nil)
+	if err == nil {
+		t.Errorf("New err returned <nil>, but an error was expected because the source type is not valid")
+	}
+}
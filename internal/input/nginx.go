@@ -1,167 +1,221 @@
 package input
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
-	"sync"
-	"time"
+	"net/url"
 
-	"github.com/nginxinc/nginx-plus-go-client/client"
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
 	nginx "github.com/nginxinc/nginx-plus-go-client/client"
 )
 
-var httpProtocol = "http://"
-
-// Cfg stores the configuration parameters for all the NGINX Plus instances to get the data from
-type Cfg struct {
-	Hosts           []NginxHost `yaml:"hosts"`
-	ClientTimeout   int         `yaml:"client_timeout"`
-	APIEndpoint     string      `yaml:"api_endpoint"`
-	Resolver        string      `yaml:"resolver"`
-	ResolverTimeout int         `yaml:"resolver_timeout"`
-}
-
 // NginxPlus stores the NGINX Plus API client and some internal configuration to fetch data from NGINX
 type NginxPlus struct {
-	Cfg         *Cfg
-	ClientsPool []*nginx.NginxClient
+	hostsPool
+	// resources are the stats fetched from the API
+	resources *Resources
 }
 
-// Task is a wrapper to store results of fetching multiple NGINX Plus instances
-type Task struct {
-	result *client.Stats
-	err    error
+// Fetch gets the stats of n NGINX Plus instances
+func (n *NginxPlus) Fetch(ctx context.Context) []*internal.Stats {
+	return n.fetchAll(ctx, func(ctx context.Context, instance *Instance) (*internal.Stats, error) {
+		return fetchResources(ctx, instance, n.resources)
+	})
 }
 
-// NginxHost stores the information about a remote host of an NGINX Plus instance
-type NginxHost struct {
-	Host       string `yaml:"host"`
-	Port       int    `yaml:"port"`
-	Resolve    bool   `yaml:"resolve"`
-	HostHeader string `yaml:"host_header"`
+// Configure sets the configuration of the NginxPlus clients
+func (n *NginxPlus) Configure(cfg *Cfg) error {
+	n.connect = connectNginxPlus
+	return n.configurePool(cfg)
 }
 
-func (nh NginxHost) String() string {
-	h := nh.Host
-	if nh.Port != 0 {
-		h = fmt.Sprintf("%v:%d", nh.Host, nh.Port)
+// connectNginxPlus creates the NGINX Plus API client of an instance
+func connectNginxPlus(instance *Instance) error {
+	nginxClient, err := nginx.NewNginxClient(instance.httpClient, instance.endpoint)
+	if err != nil {
+		return err
 	}
-	return fmt.Sprintf("%v (resolved: %v)", h, nh.Resolve)
+	instance.Client = nginxClient
+	return nil
 }
 
-func (n *NginxPlus) asyncFetchGlobalStats() []Task {
-	var wg sync.WaitGroup
-	finishedTasks := make([]Task, len(n.ClientsPool))
-
-	for i, nginxClient := range n.ClientsPool {
-		wg.Add(1)
-		go func(index int, nginxClient *client.NginxClient) {
-			defer wg.Done()
-			result, err := nginxClient.GetStats()
-			t := Task{
-				result: result,
-				err:    err,
-			}
-			finishedTasks[index] = t
-		}(i, nginxClient)
-	}
-	wg.Wait()
-
-	return finishedTasks
-}
+// fetchResources gets from the NGINX Plus API only the stats of the resources.
+// The upstreams and server zones not found in the instance are not returned.
+func fetchResources(ctx context.Context, instance *Instance, resources *Resources) (*internal.Stats, error) {
+	s := &internal.Stats{
+		Upstreams:         make(map[string]internal.Upstream, len(resources.Upstreams)),
+		ServerZones:       make(map[string]internal.ServerZone, len(resources.ServerZones)),
+		StreamUpstreams:   make(map[string]internal.Upstream, len(resources.StreamUpstreams)),
+		StreamServerZones: make(map[string]internal.ServerZone, len(resources.StreamServerZones)),
+	}
 
-// Fetch gets the stats of n NGINX Plus instances
-func (n *NginxPlus) Fetch() []*client.Stats {
-	finishedTasks := n.asyncFetchGlobalStats()
-	var statsSlice []*client.Stats
-	for _, task := range finishedTasks {
-		if task.err != nil {
-			log.Printf("error fetching from NGINX Plus instance: %v", task.err)
-		} else {
-			statsSlice = append(statsSlice, task.result)
-		}
+	if err := fetchGlobalStats(ctx, instance, resources, s); err != nil {
+		return nil, err
 	}
-	return statsSlice
-}
 
-// Configure sets the configuration of the NginxPlus clients
-func (n *NginxPlus) Configure(cfg *Cfg) error {
-	if len(cfg.Hosts) == 0 {
-		return fmt.Errorf("the NGINX Plus Fetcher requires at least 1 host to be defined")
-	}
-	n.Cfg = cfg
-	var resolvedHosts []NginxHost
-
-	resolver := NewResolver(cfg.Resolver, cfg.ResolverTimeout)
-	for _, nHost := range n.Cfg.Hosts {
-		var addrs []string
-		var err error
-		if nHost.Resolve {
-			addrs, err = resolver.Lookup(nHost.Host)
-			if err != nil {
-				return fmt.Errorf("error trying to resolve address for [%v]: %w", nHost.Host, err)
-			}
-		} else {
-			addrs = append(addrs, nHost.Host)
+	for _, name := range resources.Upstreams {
+		var ups nginx.Upstream
+		found, err := getAPI(ctx, instance, "http/upstreams/"+url.PathEscape(name), &ups)
+		if err != nil {
+			return nil, err
 		}
+		if found {
+			s.Upstreams[name] = convertUpstream(&ups)
+		}
+	}
 
-		for _, addr := range addrs {
-			newHost := NginxHost{
-				Host:       addr,
-				Resolve:    nHost.Resolve,
-				HostHeader: nHost.HostHeader,
-				Port:       nHost.Port,
-			}
-			resolvedHosts = append(resolvedHosts, newHost)
+	for _, name := range resources.ServerZones {
+		var zone nginx.ServerZone
+		found, err := getAPI(ctx, instance, "http/server_zones/"+url.PathEscape(name), &zone)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			s.ServerZones[name] = convertServerZone(&zone)
 		}
 	}
 
-	log.Printf("Creating clients for NGINX Plus hosts: %v", resolvedHosts)
+	for _, name := range resources.StreamUpstreams {
+		var ups nginx.StreamUpstream
+		found, err := getAPI(ctx, instance, "stream/upstreams/"+url.PathEscape(name), &ups)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			s.StreamUpstreams[name] = convertStreamUpstream(&ups)
+		}
+	}
 
-	for _, nHost := range resolvedHosts {
-		hostHeader := nHost.Host
-		if nHost.Resolve {
-			hostHeader = nHost.HostHeader
+	for _, name := range resources.StreamServerZones {
+		var zone nginx.StreamServerZone
+		found, err := getAPI(ctx, instance, "stream/server_zones/"+url.PathEscape(name), &zone)
+		if err != nil {
+			return nil, err
 		}
-		newHost := nHost.Host
-		if nHost.Port != 0 {
-			newHost = fmt.Sprintf("%v:%d", nHost.Host, nHost.Port)
+		if found {
+			s.StreamServerZones[name] = convertStreamServerZone(&zone)
 		}
-		httpClient := &http.Client{
-			Timeout:   time.Duration(n.Cfg.ClientTimeout) * time.Second,
-			Transport: newHostHeaderEnforcerTransport(hostHeader),
+	}
+
+	return s, nil
+}
+
+// fetchGlobalStats sets in s the connections and the HTTP requests of the instance, if they are part of the resources
+func fetchGlobalStats(ctx context.Context, instance *Instance, resources *Resources, s *internal.Stats) error {
+	if resources.Connections {
+		var connections nginx.Connections
+		if err := getRequiredAPI(ctx, instance, "connections", &connections); err != nil {
+			return err
 		}
+		s.Connections = connections.Active
+	}
 
-		nginxClient, err := nginx.NewNginxClient(httpClient, constructFullEndpoint(httpProtocol, newHost, n.Cfg.APIEndpoint))
-		if err != nil {
+	if resources.Requests {
+		var requests nginx.HTTPRequests
+		if err := getRequiredAPI(ctx, instance, "http/requests", &requests); err != nil {
 			return err
 		}
-		log.Printf("New NGINX Plus host configured: [%v] %v", hostHeader, newHost)
-		n.ClientsPool = append(n.ClientsPool, nginxClient)
+		s.Requests = requests.Total
 	}
+	return nil
+}
 
+// getRequiredAPI works like getAPI, but it returns an error if the path is not found
+func getRequiredAPI(ctx context.Context, instance *Instance, path string, v interface{}) error {
+	found, err := getAPI(ctx, instance, path, v)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("%v not found in the NGINX Plus API", path)
+	}
 	return nil
 }
 
-// hostHeaderEnforcerTransport is an implementation of http.Transport that will define a custom RoundTrip method.
-type hostHeaderEnforcerTransport struct {
-	http.Transport
-	host string
+// getAPI gets a path of the NGINX Plus API and decodes the response into v. It returns false if the path is not found.
+func getAPI(ctx context.Context, instance *Instance, path string, v interface{}) (bool, error) {
+	u := fmt.Sprintf("%v/%v/%v", instance.endpoint, nginx.APIVersion, path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return false, fmt.Errorf("error creating the request for %v: %w", path, err)
+	}
+
+	resp, err := instance.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("error getting %v: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		// The body is drained so the connection can be reused
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return false, fmt.Errorf("error getting %v: expected %v response, got %v", path, http.StatusOK, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return false, fmt.Errorf("error decoding %v: %w", path, err)
+	}
+	return true, nil
+}
+
+// convertUpstream returns the normalised stats of an NGINX Plus upstream
+func convertUpstream(ups *nginx.Upstream) internal.Upstream {
+	peers := make([]internal.Peer, 0, len(ups.Peers))
+	for _, p := range ups.Peers {
+		peers = append(peers, internal.Peer{
+			State:        p.State,
+			Active:       p.Active,
+			Requests:     p.Requests,
+			Responses5xx: p.Responses.Responses5xx,
+			Sent:         p.Sent,
+			Received:     p.Received,
+			ResponseTime: p.ResponseTime,
+		})
+	}
+	return internal.Upstream{Peers: peers}
 }
 
-func newHostHeaderEnforcerTransport(host string) *hostHeaderEnforcerTransport {
-	return &hostHeaderEnforcerTransport{
-		host: host,
+// convertStreamUpstream returns the normalised stats of an NGINX Plus stream upstream
+func convertStreamUpstream(ups *nginx.StreamUpstream) internal.Upstream {
+	peers := make([]internal.Peer, 0, len(ups.Peers))
+	for _, p := range ups.Peers {
+		peers = append(peers, internal.Peer{
+			State:        p.State,
+			Active:       p.Active,
+			Requests:     p.Connections,
+			Sent:         p.Sent,
+			Received:     p.Received,
+			ResponseTime: p.ResponseTime,
+		})
 	}
+	return internal.Upstream{Peers: peers}
 }
 
-// RoundTrip overrides the RoundTrip method in the hostHeaderEnforcerTransport to update the Host header with the configured value by the user.
-func (ct *hostHeaderEnforcerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	req.Host = ct.host
-	return ct.Transport.RoundTrip(req)
+// convertServerZone returns the normalised stats of an NGINX Plus server zone
+func convertServerZone(zone *nginx.ServerZone) internal.ServerZone {
+	return internal.ServerZone{
+		Processing:   zone.Processing,
+		Requests:     zone.Requests,
+		Responses5xx: zone.Responses.Responses5xx,
+		Sent:         zone.Sent,
+		Received:     zone.Received,
+	}
 }
 
-func constructFullEndpoint(protocol, host, endpoint string) string {
-	return fmt.Sprintf("%s%s%s", protocol, host, endpoint)
+// convertStreamServerZone returns the normalised stats of an NGINX Plus stream server zone
+func convertStreamServerZone(zone *nginx.StreamServerZone) internal.ServerZone {
+	return internal.ServerZone{
+		Processing:   zone.Processing,
+		Requests:     zone.Connections,
+		Responses5xx: zone.Sessions.Sessions5xx,
+		Sent:         zone.Sent,
+		Received:     zone.Received,
+	}
 }
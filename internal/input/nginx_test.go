@@ -1,8 +1,20 @@
 package input
 
 import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+	nginx "github.com/nginxinc/nginx-plus-go-client/client"
 )
 
 func TestConstructFullEndpoint(t *testing.T) {
@@ -39,3 +51,301 @@ func TestConfigureNginxPlus(t *testing.T) {
 		}
 	}
 }
+
+func TestRefreshClientsPool(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("[4,5,6,7,8]"))
+	}))
+	defer server.Close()
+
+	port, err := strconv.Atoi(server.URL[strings.LastIndex(server.URL, ":")+1:])
+	if err != nil {
+		t.Fatalf("error getting the port of the test server: %v", err)
+	}
+
+	nginxPlus := NginxPlus{}
+	err = nginxPlus.Configure(&Cfg{
+		Hosts:           []NginxHost{{Host: "localhost", Port: port, Resolve: true}},
+		ClientTimeout:   1,
+		ResolveInterval: 60,
+	})
+	if err != nil {
+		t.Fatalf("NGINX Plus configuration returned an unexpected error: %v", err)
+	}
+	resolvedAddresses := len(nginxPlus.ClientsPool)
+	if resolvedAddresses == 0 {
+		t.Fatalf("NGINX Plus configuration didn't create any client for localhost")
+	}
+
+	// A client that is no longer resolved must be removed and a missing address must be added again
+	nginxPlus.ClientsPool[0] = &Instance{Host: NginxHost{Host: "192.0.2.1", Port: port, Resolve: true}}
+	nginxPlus.refreshClientsPool()
+
+	if len(nginxPlus.ClientsPool) != resolvedAddresses {
+		t.Errorf("refreshClientsPool returned %v clients, but %v expected", len(nginxPlus.ClientsPool), resolvedAddresses)
+	}
+	for _, instance := range nginxPlus.ClientsPool {
+		if instance.Host.Host == "192.0.2.1" {
+			t.Errorf("refreshClientsPool didn't remove the client of an address that is not resolved anymore")
+		}
+		if instance.Client == nil {
+			t.Errorf("refreshClientsPool didn't create the client for address %v", instance.Host.Host)
+		}
+	}
+
+	if !nginxPlus.nextResolve.After(time.Now()) {
+		t.Errorf("refreshClientsPool didn't schedule the next resolution")
+	}
+}
+
+func TestConfigureNginxPlusTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("[4,5,6,7,8]"))
+	}))
+	defer server.Close()
+
+	port, err := strconv.Atoi(server.URL[strings.LastIndex(server.URL, ":")+1:])
+	if err != nil {
+		t.Fatalf("error getting the port of the test server: %v", err)
+	}
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caCert, certPEM, 0o600); err != nil {
+		t.Fatalf("error writing the CA certificate: %v", err)
+	}
+
+	testCases := []struct {
+		host    NginxHost
+		tls     TLSCfg
+		wantErr bool
+		msg     string
+	}{
+		{
+			host:    NginxHost{Host: "127.0.0.1", Port: port},
+			tls:     TLSCfg{Enabled: true, CACert: caCert},
+			wantErr: false,
+			msg:     "certificate verified using the CA",
+		},
+		{
+			host:    NginxHost{Host: "127.0.0.1", Port: port},
+			tls:     TLSCfg{Enabled: true},
+			wantErr: true,
+			msg:     "certificate signed by an unknown authority",
+		},
+		{
+			host:    NginxHost{Host: "127.0.0.1", Port: port},
+			tls:     TLSCfg{Enabled: true, InsecureSkipVerify: true},
+			wantErr: false,
+			msg:     "certificate verification disabled",
+		},
+		{
+			host:    NginxHost{Host: "localhost", Port: port, Resolve: true, HostHeader: "example.com"},
+			tls:     TLSCfg{Enabled: true, CACert: caCert},
+			wantErr: false,
+			msg:     "certificate verified against the host header",
+		},
+		{
+			host:    NginxHost{Host: "localhost", Port: port, Resolve: true, HostHeader: "nginx.org"},
+			tls:     TLSCfg{Enabled: true, CACert: caCert},
+			wantErr: true,
+			msg:     "host header not valid for the certificate",
+		},
+		{
+			host:    NginxHost{Host: "localhost", Port: port, Resolve: true, HostHeader: "nginx.org"},
+			tls:     TLSCfg{Enabled: true, CACert: caCert, ServerName: "example.com"},
+			wantErr: false,
+			msg:     "server name overrides the host header",
+		},
+		{
+			host:    NginxHost{Host: "127.0.0.1", Port: port, HostHeader: "nginx.org"},
+			tls:     TLSCfg{Enabled: true, CACert: caCert},
+			wantErr: false,
+			msg:     "certificate verified against the host when it's not resolved",
+		},
+		{
+			host:    NginxHost{Host: "127.0.0.1", Port: port, TLS: &TLSCfg{Enabled: true, CACert: caCert}},
+			tls:     TLSCfg{},
+			wantErr: false,
+			msg:     "TLS enabled only for the host",
+		},
+		{
+			host:    NginxHost{Host: "127.0.0.1", Port: port},
+			tls:     TLSCfg{Enabled: true, CACert: caCert, ClientCert: caCert},
+			wantErr: true,
+			msg:     "client certificate without a key",
+		},
+	}
+
+	for _, testCase := range testCases {
+		nginxPlus := NginxPlus{}
+		err := nginxPlus.Configure(&Cfg{
+			Hosts:         []NginxHost{testCase.host},
+			ClientTimeout: 1,
+			TLS:           testCase.tls,
+		})
+		if err == nil && testCase.wantErr {
+			t.Errorf("NGINX Plus configuration err returned <nil>, but an error was expected for case: %v", testCase.msg)
+		}
+		if err != nil && !testCase.wantErr {
+			t.Errorf("NGINX Plus configuration returned an err: %v for case: %v", err, testCase.msg)
+		}
+	}
+}
+
+func TestConvertResources(t *testing.T) {
+	stats := &nginx.Stats{
+		Upstreams: nginx.Upstreams{
+			"backend": {Peers: []nginx.Peer{
+				{State: "up", Active: 2, Requests: 100, Responses: nginx.Responses{Responses5xx: 5}, Sent: 1000, Received: 2000, ResponseTime: 30},
+				{State: "unhealthy", Active: 1},
+			}},
+		},
+		ServerZones: nginx.ServerZones{
+			"example.com": {Processing: 3, Requests: 200, Responses: nginx.Responses{Responses5xx: 7}, Sent: 3000, Received: 4000},
+		},
+		StreamUpstreams: nginx.StreamUpstreams{
+			"dns": {Peers: []nginx.StreamPeer{
+				{State: "up", Active: 4, Connections: 50, Sent: 500, Received: 600},
+			}},
+		},
+		StreamServerZones: nginx.StreamServerZones{
+			"dns_zone": {Processing: 6, Connections: 60, Sessions: nginx.Sessions{Sessions5xx: 2}, Sent: 700, Received: 800},
+		},
+	}
+
+	expected := &internal.Stats{
+		Upstreams: map[string]internal.Upstream{
+			"backend": {Peers: []internal.Peer{
+				{State: "up", Active: 2, Requests: 100, Responses5xx: 5, Sent: 1000, Received: 2000, ResponseTime: 30},
+				{State: "unhealthy", Active: 1},
+			}},
+		},
+		ServerZones: map[string]internal.ServerZone{
+			"example.com": {Processing: 3, Requests: 200, Responses5xx: 7, Sent: 3000, Received: 4000},
+		},
+		StreamUpstreams: map[string]internal.Upstream{
+			"dns": {Peers: []internal.Peer{
+				{State: "up", Active: 4, Requests: 50, Sent: 500, Received: 600},
+			}},
+		},
+		StreamServerZones: map[string]internal.ServerZone{
+			"dns_zone": {Processing: 6, Requests: 60, Responses5xx: 2, Sent: 700, Received: 800},
+		},
+	}
+
+	ups := stats.Upstreams["backend"]
+	if converted := convertUpstream(&ups); !reflect.DeepEqual(converted, expected.Upstreams["backend"]) {
+		t.Errorf("convertUpstream returned %+v, but %+v expected", converted, expected.Upstreams["backend"])
+	}
+	zone := stats.ServerZones["example.com"]
+	if converted := convertServerZone(&zone); !reflect.DeepEqual(converted, expected.ServerZones["example.com"]) {
+		t.Errorf("convertServerZone returned %+v, but %+v expected", converted, expected.ServerZones["example.com"])
+	}
+	streamUps := stats.StreamUpstreams["dns"]
+	if converted := convertStreamUpstream(&streamUps); !reflect.DeepEqual(converted, expected.StreamUpstreams["dns"]) {
+		t.Errorf("convertStreamUpstream returned %+v, but %+v expected", converted, expected.StreamUpstreams["dns"])
+	}
+	streamZone := stats.StreamServerZones["dns_zone"]
+	if converted := convertStreamServerZone(&streamZone); !reflect.DeepEqual(converted, expected.StreamServerZones["dns_zone"]) {
+		t.Errorf("convertStreamServerZone returned %+v, but %+v expected", converted, expected.StreamServerZones["dns_zone"])
+	}
+}
+
+func TestNginxPlusFetchResources(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api", "/api/":
+			_, _ = w.Write([]byte("[4,5,6,7,8]"))
+			return
+		case "/api/8/connections":
+			_, _ = w.Write([]byte(`{"active": 7, "idle": 3}`))
+		case "/api/8/http/requests":
+			_, _ = w.Write([]byte(`{"total": 50, "current": 1}`))
+		case "/api/8/http/upstreams/backend":
+			_, _ = w.Write([]byte(`{"peers": [{"state": "up", "active": 4}, {"state": "unavail", "active": 0}]}`))
+		case "/api/8/http/server_zones/zone one":
+			_, _ = w.Write([]byte(`{"processing": 2}`))
+		case "/api/8/stream/upstreams/dns":
+			_, _ = w.Write([]byte(`{"peers": [{"state": "up", "active": 5}]}`))
+		case "/api/8/stream/server_zones/dns_zone":
+			_, _ = w.Write([]byte(`{"processing": 8, "connections": 100}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+		requested = append(requested, r.URL.Path)
+	}))
+	defer server.Close()
+
+	nginxPlus := &NginxPlus{resources: &Resources{
+		Connections:       true,
+		Requests:          true,
+		Upstreams:         []string{"backend", "missing"},
+		ServerZones:       []string{"zone one"},
+		StreamUpstreams:   []string{"dns"},
+		StreamServerZones: []string{"dns_zone"},
+	}}
+	err := nginxPlus.Configure(&Cfg{
+		Hosts:         []NginxHost{{Host: server.Listener.Addr().String()}},
+		ClientTimeout: 1,
+		APIEndpoint:   "/api",
+	})
+	if err != nil {
+		t.Fatalf("NGINX Plus configuration returned an unexpected error: %v", err)
+	}
+
+	expected := []*internal.Stats{{
+		Instance:    server.Listener.Addr().String(),
+		Connections: 7,
+		Requests:    50,
+		Upstreams: map[string]internal.Upstream{
+			"backend": {Peers: []internal.Peer{{State: "up", Active: 4}, {State: "unavail", Active: 0}}},
+		},
+		ServerZones: map[string]internal.ServerZone{
+			"zone one": {Processing: 2},
+		},
+		StreamUpstreams: map[string]internal.Upstream{
+			"dns": {Peers: []internal.Peer{{State: "up", Active: 5}}},
+		},
+		StreamServerZones: map[string]internal.ServerZone{
+			"dns_zone": {Processing: 8, Requests: 100},
+		},
+	}}
+	stats := nginxPlus.Fetch(context.Background())
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("NginxPlus.Fetch returned %+v, but %+v expected", stats, expected)
+	}
+
+	expectedRequests := []string{"/api/8/connections", "/api/8/http/requests", "/api/8/http/upstreams/backend", "/api/8/http/upstreams/missing", "/api/8/http/server_zones/zone one",
+		"/api/8/stream/upstreams/dns", "/api/8/stream/server_zones/dns_zone"}
+	if !reflect.DeepEqual(requested, expectedRequests) {
+		t.Errorf("NginxPlus.Fetch requested %v, but %v expected", requested, expectedRequests)
+	}
+}
+
+func TestNginxPlusFetchResourcesNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api", "/api/":
+			_, _ = w.Write([]byte("[4,5,6,7,8]"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	nginxPlus := &NginxPlus{resources: &Resources{Connections: true}}
+	err := nginxPlus.Configure(&Cfg{
+		Hosts:         []NginxHost{{Host: server.Listener.Addr().String()}},
+		ClientTimeout: 1,
+		APIEndpoint:   "/api",
+	})
+	if err != nil {
+		t.Fatalf("NGINX Plus configuration returned an unexpected error: %v", err)
+	}
+
+	if stats := nginxPlus.Fetch(context.Background()); len(stats) != 0 {
+		t.Errorf("NginxPlus.Fetch returned %+v, but no stats expected when the connections are not found", stats)
+	}
+}
@@ -0,0 +1,506 @@
+package input
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+	"github.com/nginxinc/nginx-ns1-gslb/internal/metrics"
+	nginx "github.com/nginxinc/nginx-plus-go-client/client"
+)
+
+var (
+	httpProtocol  = "http://"
+	httpsProtocol = "https://"
+)
+
+// minResolveInterval is the lowest time to wait between two resolutions of the hosts, even if the DNS TTL is lower
+const minResolveInterval = time.Second
+
+// TLSCfg stores the parameters to connect to the NGINX Plus API using HTTPS
+type TLSCfg struct {
+	Enabled            bool   `yaml:"enabled"`
+	CACert             string `yaml:"ca_cert"`
+	ClientCert         string `yaml:"client_cert"`
+	ClientKey          string `yaml:"client_key"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// NginxHost stores the information about a remote host of an NGINX Plus instance
+type NginxHost struct {
+	Host       string `yaml:"host"`
+	Port       int    `yaml:"port"`
+	Resolve    bool   `yaml:"resolve"`
+	HostHeader string `yaml:"host_header"`
+	// TLS overrides the global TLS configuration for this host
+	TLS *TLSCfg `yaml:"tls"`
+	// Auth overrides the global credentials for this host
+	Auth *AuthCfg `yaml:"auth"`
+}
+
+func (nh NginxHost) String() string {
+	return fmt.Sprintf("%v (resolved: %v)", nh.address(), nh.Resolve)
+}
+
+// address returns the host including the port (if any)
+func (nh NginxHost) address() string {
+	if nh.Port != 0 {
+		return fmt.Sprintf("%v:%d", nh.Host, nh.Port)
+	}
+	return nh.Host
+}
+
+// Instance stores the clients of a single NGINX host (or resolved address)
+type Instance struct {
+	Host NginxHost
+	// Client is the NGINX Plus API client. Only used by the NginxPlus source
+	Client     *nginx.NginxClient
+	httpClient *http.Client
+	endpoint   string
+	// origin is the index of the configured host in Cfg.Hosts this instance was created from
+	origin int
+	// breaker is nil if the circuit breaker is disabled
+	breaker *breaker
+}
+
+// Task is a wrapper to store results of fetching multiple NGINX instances
+type Task struct {
+	result *internal.Stats
+	err    error
+}
+
+// resolvedHost is a resolved address of a configured host
+type resolvedHost struct {
+	host   NginxHost
+	origin int
+}
+
+func (rh resolvedHost) key() string {
+	return fmt.Sprintf("%d/%v", rh.origin, rh.host.address())
+}
+
+// hostsPool resolves the configured hosts and keeps an Instance for every address
+type hostsPool struct {
+	Cfg         *Cfg
+	ClientsPool []*Instance
+	resolver    *Resolver
+	nextResolve time.Time
+	// connect is called for every new Instance to finish its configuration and check the host is reachable
+	connect func(instance *Instance) error
+}
+
+// configurePool resolves all the hosts and creates an Instance for every address
+func (p *hostsPool) configurePool(cfg *Cfg) error {
+	if len(cfg.Hosts) == 0 {
+		return fmt.Errorf("the NGINX Plus Fetcher requires at least 1 host to be defined")
+	}
+	p.Cfg = cfg
+	p.ClientsPool = nil
+	p.resolver = NewResolver(cfg.Resolver, cfg.ResolverTimeout)
+
+	var resolvedHosts []resolvedHost
+	var hosts []NginxHost
+	minTTL := time.Duration(0)
+	for i, nHost := range p.Cfg.Hosts {
+		rHosts, ttl, err := p.resolveHost(i, nHost)
+		if err != nil {
+			return err
+		}
+		resolvedHosts = append(resolvedHosts, rHosts...)
+		for _, rh := range rHosts {
+			hosts = append(hosts, rh.host)
+		}
+		minTTL = lowestTTL(minTTL, ttl)
+	}
+
+	log.Printf("Creating clients for NGINX Plus hosts: %v", hosts)
+
+	for _, rh := range resolvedHosts {
+		instance, err := p.newInstance(rh)
+		if err != nil {
+			return err
+		}
+		p.ClientsPool = append(p.ClientsPool, instance)
+	}
+
+	p.scheduleResolve(minTTL)
+
+	return nil
+}
+
+// fetchAll calls fetch for all the instances at the same time, refreshing the pool first if the hosts need to be resolved again.
+// The stats of the instances that failed are not returned. If ctx is canceled, nothing is returned.
+func (p *hostsPool) fetchAll(ctx context.Context, fetch func(ctx context.Context, instance *Instance) (*internal.Stats, error)) []*internal.Stats {
+	if p.Cfg.ResolveInterval > 0 && time.Now().After(p.nextResolve) {
+		p.refreshClientsPool()
+	}
+
+	// The retries of all the hosts share the same deadline, so the fetch ends in time for the rest of the cycle
+	var retryDeadline time.Time
+	if p.Cfg.FetchRetryMaxDuration > 0 {
+		retryDeadline = time.Now().Add(p.Cfg.FetchRetryMaxDuration)
+	}
+
+	var wg sync.WaitGroup
+	finishedTasks := make([]Task, len(p.ClientsPool))
+
+	for i, instance := range p.ClientsPool {
+		wg.Add(1)
+		go func(index int, instance *Instance) {
+			defer wg.Done()
+			result, err := p.fetchInstance(ctx, instance, retryDeadline, fetch)
+			finishedTasks[index] = Task{
+				result: result,
+				err:    err,
+			}
+		}(i, instance)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// The pending requests end on their own once the client timeout expires
+		log.Printf("fetching from NGINX Plus instances aborted: %v", ctx.Err())
+		return nil
+	}
+
+	var statsSlice []*internal.Stats
+	for i, task := range finishedTasks {
+		if task.err != nil {
+			log.Printf("error fetching from NGINX instance %v: %v", p.ClientsPool[i].Host.address(), task.err)
+		} else {
+			task.result.Instance = p.ClientsPool[i].Host.address()
+			statsSlice = append(statsSlice, task.result)
+		}
+	}
+	return statsSlice
+}
+
+// fetchInstance calls fetch for an instance, retrying if it fails. The retries stop when the next one would start after
+// retryDeadline, unless it's zero. The instance is skipped while its circuit breaker is open.
+func (p *hostsPool) fetchInstance(ctx context.Context, instance *Instance, retryDeadline time.Time, fetch func(ctx context.Context, instance *Instance) (*internal.Stats, error)) (*internal.Stats, error) {
+	host := instance.Host.address()
+	retries := p.Cfg.FetchRetries
+	if instance.breaker != nil {
+		if !instance.breaker.allow(time.Now()) {
+			return nil, errBreakerOpen
+		}
+		if instance.breaker.state == breakerHalfOpen {
+			log.Printf("circuit breaker of NGINX instance %v is %v, probing the host", host, breakerHalfOpen)
+			// A single attempt is enough to know if the host is back
+			retries = 0
+		}
+	}
+
+	backoff := time.Duration(p.Cfg.FetchRetryBackoff) * time.Second
+	var result *internal.Stats
+	var err error
+	for retry := 0; ; retry++ {
+		metrics.FetchAttempts.WithLabelValues(host).Inc()
+		result, err = fetch(ctx, instance)
+		if err == nil {
+			break
+		}
+		metrics.FetchFailures.WithLabelValues(host).Inc()
+		if retry >= retries || ctx.Err() != nil {
+			break
+		}
+		if !retryDeadline.IsZero() && time.Now().Add(backoff).After(retryDeadline) {
+			log.Printf("error fetching from NGINX instance %v, not retried because the next retry would exceed the interval: %v", host, err)
+			break
+		}
+
+		log.Printf("error fetching from NGINX instance %v, retrying in %v (%d/%d): %v", host, backoff, retry+1, retries, err)
+		if !sleep(ctx, backoff) {
+			break
+		}
+		backoff *= 2
+	}
+
+	if instance.breaker != nil {
+		p.updateBreaker(instance, err)
+	}
+	return result, err
+}
+
+// Instances returns the number of instances in the pool
+func (p *hostsPool) Instances() int {
+	return len(p.ClientsPool)
+}
+
+// updateBreaker records the result of the fetch of an instance in its circuit breaker, logging the changes of state
+func (p *hostsPool) updateBreaker(instance *Instance, err error) {
+	host := instance.Host.address()
+	previous := instance.breaker.state
+	if err != nil {
+		instance.breaker.failure(time.Now())
+	} else {
+		instance.breaker.success()
+	}
+
+	state := instance.breaker.state
+	metrics.FetchBreakerState.WithLabelValues(host).Set(float64(state))
+	if state == previous {
+		return
+	}
+	if state == breakerOpen {
+		log.Printf("circuit breaker of NGINX instance %v is %v after %d consecutive failures, the host will be skipped for %v",
+			host, state, instance.breaker.failures, instance.breaker.openTimeout)
+	} else {
+		log.Printf("circuit breaker of NGINX instance %v is %v", host, state)
+	}
+}
+
+// sleep waits for the duration d. It returns false if ctx is canceled before.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// resolveHost returns the addresses of a configured host and the TTL of the DNS answer (if known)
+func (p *hostsPool) resolveHost(origin int, nHost NginxHost) ([]resolvedHost, time.Duration, error) {
+	if !nHost.Resolve {
+		return []resolvedHost{{host: nHost, origin: origin}}, 0, nil
+	}
+
+	addrs, ttl, err := p.resolver.LookupWithTTL(nHost.Host)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error trying to resolve address for [%v]: %w", nHost.Host, err)
+	}
+
+	resolvedHosts := make([]resolvedHost, 0, len(addrs))
+	for _, addr := range addrs {
+		newHost := nHost
+		newHost.Host = addr
+		resolvedHosts = append(resolvedHosts, resolvedHost{host: newHost, origin: origin})
+	}
+	return resolvedHosts, ttl, nil
+}
+
+// refreshClientsPool resolves again the hosts with resolve enabled, creating clients for the new addresses
+// and removing the clients of the addresses that are not returned anymore.
+func (p *hostsPool) refreshClientsPool() {
+	current := make(map[string]*Instance, len(p.ClientsPool))
+	for _, instance := range p.ClientsPool {
+		current[resolvedHost{host: instance.Host, origin: instance.origin}.key()] = instance
+	}
+
+	var newPool []*Instance
+	kept := make(map[string]bool)
+	minTTL := time.Duration(0)
+	for i, nHost := range p.Cfg.Hosts {
+		rHosts, ttl, err := p.resolveHost(i, nHost)
+		if err != nil {
+			// Keep using the addresses we already have for this host until it can be resolved again
+			log.Printf("error re-resolving NGINX Plus host, the previous addresses will be kept: %v", err)
+			for _, instance := range p.ClientsPool {
+				if instance.origin == i {
+					newPool = append(newPool, instance)
+					kept[resolvedHost{host: instance.Host, origin: instance.origin}.key()] = true
+				}
+			}
+			continue
+		}
+		minTTL = lowestTTL(minTTL, ttl)
+
+		for _, rh := range rHosts {
+			key := rh.key()
+			if kept[key] {
+				continue
+			}
+			if instance, ok := current[key]; ok {
+				newPool = append(newPool, instance)
+				kept[key] = true
+				continue
+			}
+
+			instance, err := p.newInstance(rh)
+			if err != nil {
+				// The address will be retried in the next resolution
+				log.Printf("error adding NGINX Plus host %v: %v", rh.host.address(), err)
+				continue
+			}
+			log.Printf("NGINX Plus host added after resolving [%v]: %v", nHost.Host, rh.host.address())
+			newPool = append(newPool, instance)
+			kept[key] = true
+		}
+	}
+
+	for key, instance := range current {
+		if !kept[key] {
+			log.Printf("NGINX Plus host removed after resolving [%v]: %v", p.Cfg.Hosts[instance.origin].Host, instance.Host.address())
+			metrics.DeleteHost(instance.Host.address())
+		}
+	}
+
+	p.ClientsPool = newPool
+	p.scheduleResolve(minTTL)
+}
+
+// scheduleResolve sets the time of the next resolution of the hosts. The resolve_interval is used unless the DNS TTL is lower.
+func (p *hostsPool) scheduleResolve(ttl time.Duration) {
+	interval := time.Duration(p.Cfg.ResolveInterval) * time.Second
+	if ttl > 0 && ttl < interval {
+		interval = ttl
+	}
+	if interval < minResolveInterval {
+		interval = minResolveInterval
+	}
+	p.nextResolve = time.Now().Add(interval)
+}
+
+// newInstance creates the http client for a resolved host
+func (p *hostsPool) newInstance(rh resolvedHost) (*Instance, error) {
+	nHost := rh.host
+	hostHeader := nHost.Host
+	if nHost.Resolve {
+		hostHeader = nHost.HostHeader
+	}
+
+	authCfg := p.Cfg.Auth
+	if nHost.Auth != nil {
+		authCfg = *nHost.Auth
+	}
+	headers, err := authCfg.headers()
+	if err != nil {
+		return nil, fmt.Errorf("error configuring authentication for NGINX Plus host %v: %w", nHost.address(), err)
+	}
+
+	transport := newAPITransport(hostHeader, headers)
+
+	protocol := httpProtocol
+	tlsCfg := p.Cfg.TLS
+	if nHost.TLS != nil {
+		tlsCfg = *nHost.TLS
+	}
+	if tlsCfg.Enabled {
+		protocol = httpsProtocol
+		// The certificate is verified against the Host header (the host_header for resolved hosts, and the host otherwise)
+		// unless a server name is explicitly configured
+		serverName := hostHeader
+		if tlsCfg.ServerName != "" {
+			serverName = tlsCfg.ServerName
+		}
+		tlsClientConfig, err := tlsCfg.clientConfig(serverName)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring TLS for NGINX Plus host %v: %w", nHost.address(), err)
+		}
+		transport.TLSClientConfig = tlsClientConfig
+	}
+
+	instance := &Instance{
+		Host: nHost,
+		httpClient: &http.Client{
+			Timeout:   time.Duration(p.Cfg.ClientTimeout) * time.Second,
+			Transport: transport,
+		},
+		endpoint: constructFullEndpoint(protocol, nHost.address(), p.Cfg.APIEndpoint),
+		origin:   rh.origin,
+	}
+	if p.Cfg.CircuitBreaker.FailureThreshold > 0 {
+		instance.breaker = newBreaker(&p.Cfg.CircuitBreaker)
+	}
+
+	if err := p.connect(instance); err != nil {
+		return nil, err
+	}
+	log.Printf("New NGINX Plus host configured: [%v] %v", hostHeader, nHost.address())
+	if instance.breaker != nil {
+		metrics.FetchBreakerState.WithLabelValues(nHost.address()).Set(float64(breakerClosed))
+	}
+
+	return instance, nil
+}
+
+// clientConfig returns the tls.Config to connect to an NGINX Plus host.
+// If serverName is empty, the host of the API endpoint will be used to verify the certificate.
+func (tc TLSCfg) clientConfig(serverName string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         serverName,
+		InsecureSkipVerify: tc.InsecureSkipVerify, // #nosec G402
+	}
+
+	if tc.CACert != "" {
+		caCert, err := os.ReadFile(tc.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA certificate at %v: %w", tc.CACert, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no valid certificates found in CA certificate at %v", tc.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if tc.ClientCert != "" || tc.ClientKey != "" {
+		if tc.ClientCert == "" || tc.ClientKey == "" {
+			return nil, fmt.Errorf("both client_cert and client_key are required to use a client certificate")
+		}
+		cert, err := tls.LoadX509KeyPair(tc.ClientCert, tc.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// lowestTTL returns the lowest of two TTLs, ignoring unknown (0) values
+func lowestTTL(a, b time.Duration) time.Duration {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// apiTransport is an implementation of http.Transport that will define a custom RoundTrip method.
+type apiTransport struct {
+	http.Transport
+	host    string
+	headers http.Header
+}
+
+func newAPITransport(host string, headers http.Header) *apiTransport {
+	return &apiTransport{
+		host:    host,
+		headers: headers,
+	}
+}
+
+// RoundTrip overrides the RoundTrip method in the apiTransport to update the Host header with the configured value by the user
+// and to add the credentials and custom headers.
+func (ct *apiTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrip must not modify the original request
+	req = req.Clone(req.Context())
+	req.Host = ct.host
+	for name, values := range ct.headers {
+		req.Header[name] = values
+	}
+	return ct.Transport.RoundTrip(req)
+}
+
+func constructFullEndpoint(protocol, host, endpoint string) string {
+	return fmt.Sprintf("%s%s%s", protocol, host, endpoint)
+}
@@ -2,9 +2,13 @@ package input
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net"
+	"sync"
 	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
 )
 
 // Resolver handles the resolution of NGINX Plus IPs using a custom DNS resolver
@@ -13,6 +17,54 @@ type Resolver struct {
 	timeout  int
 }
 
+// ttlCollectorKey is the context key used to pass a ttlCollector to the custom resolver Dial function
+type ttlCollectorKey struct{}
+
+// ttlCollector stores the lowest TTL of the answers received by the custom resolver during a lookup
+type ttlCollector struct {
+	mu  sync.Mutex
+	ttl uint32
+	set bool
+}
+
+func (c *ttlCollector) add(ttl uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.set || ttl < c.ttl {
+		c.ttl = ttl
+		c.set = true
+	}
+}
+
+func (c *ttlCollector) duration() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Duration(c.ttl) * time.Second
+}
+
+// packetConn is implemented by the UDP connections. The Go resolver only sends a DNS message per packet when the
+// connection implements net.PacketConn, otherwise it frames the messages as if it was a TCP connection.
+type packetConn interface {
+	net.Conn
+	net.PacketConn
+}
+
+// ttlConn wraps the UDP connection to the custom resolver to read the TTLs of the DNS answers
+type ttlConn struct {
+	packetConn
+	collector *ttlCollector
+}
+
+func (c *ttlConn) Read(b []byte) (int, error) {
+	n, err := c.packetConn.Read(b)
+	if n > 0 {
+		if ttl, ok := minAnswerTTL(b[:n]); ok {
+			c.collector.add(ttl)
+		}
+	}
+	return n, err
+}
+
 // NewResolver returns a new instance of the Resolver
 func NewResolver(resolver string, timeout int) *Resolver {
 	if resolver == "" {
@@ -26,7 +78,15 @@ func NewResolver(resolver string, timeout int) *Resolver {
 		resolver: &net.Resolver{
 			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
 				d := net.Dialer{}
-				return d.DialContext(ctx, "udp", resolver)
+				conn, err := d.DialContext(ctx, "udp", resolver)
+				if err != nil {
+					return nil, err
+				}
+				collector, ok := ctx.Value(ttlCollectorKey{}).(*ttlCollector)
+				if pc, isPacketConn := conn.(packetConn); ok && isPacketConn {
+					return &ttlConn{packetConn: pc, collector: collector}, nil
+				}
+				return conn, nil
 			},
 			PreferGo: true,
 		},
@@ -36,16 +96,63 @@ func NewResolver(resolver string, timeout int) *Resolver {
 
 // Lookup returns a list of IP Addresses for a given host using the custom resolver. If not resolver defined, the local resolver is used.
 func (r *Resolver) Lookup(host string) ([]string, error) {
+	addrs, _, err := r.LookupWithTTL(host)
+	return addrs, err
+}
+
+// LookupWithTTL works like Lookup but also returns the lowest TTL of the DNS answers.
+// The TTL is only known when a custom resolver is used, otherwise 0 is returned.
+func (r *Resolver) LookupWithTTL(host string) ([]string, time.Duration, error) {
 	if r.resolver == nil {
-		return localLookup(host)
+		addrs, err := localLookup(host)
+		return addrs, 0, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(r.timeout)*time.Second)
+	collector := &ttlCollector{}
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), ttlCollectorKey{}, collector), time.Duration(r.timeout)*time.Second)
 	defer cancel()
 
-	return r.resolver.LookupHost(ctx, host)
+	addrs, err := r.resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return addrs, collector.duration(), nil
 }
 
 func localLookup(host string) ([]string, error) {
 	return net.LookupHost(host)
 }
+
+// minAnswerTTL parses a DNS response message and returns the lowest TTL of the records in the answer section.
+// false is returned if the message can't be parsed or there are no answers.
+func minAnswerTTL(msg []byte) (uint32, bool) {
+	var parser dnsmessage.Parser
+	if _, err := parser.Start(msg); err != nil {
+		return 0, false
+	}
+	if err := parser.SkipAllQuestions(); err != nil {
+		return 0, false
+	}
+
+	var minTTL uint32
+	found := false
+	for {
+		header, err := parser.AnswerHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			break
+		}
+		if err != nil {
+			return 0, false
+		}
+		if err := parser.SkipAnswer(); err != nil {
+			return 0, false
+		}
+		if !found || header.TTL < minTTL {
+			minTTL = header.TTL
+			found = true
+		}
+	}
+
+	return minTTL, found
+}
@@ -1,7 +1,11 @@
 package input
 
 import (
+	"net"
 	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
 )
 
 func TestResolverLookup(t *testing.T) {
@@ -36,3 +40,109 @@ func TestResolverExternalWrongAddress(t *testing.T) {
 		t.Errorf("NewResolver err returned nil but expected an error because resolver address (%v) has no port", resolverAddress)
 	}
 }
+
+// serveDNS answers the A queries received on conn with addr and the AAAA queries without records, until conn is closed
+func serveDNS(conn net.PacketConn, addr [4]byte, ttl uint32) {
+	buf := make([]byte, 512)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		var parser dnsmessage.Parser
+		header, err := parser.Start(buf[:n])
+		if err != nil {
+			continue
+		}
+		question, err := parser.Question()
+		if err != nil {
+			continue
+		}
+
+		builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: header.ID, Response: true, Authoritative: true})
+		builder.EnableCompression()
+		_ = builder.StartQuestions()
+		_ = builder.Question(question)
+		_ = builder.StartAnswers()
+		if question.Type == dnsmessage.TypeA {
+			_ = builder.AResource(dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: ttl}, dnsmessage.AResource{A: addr})
+		}
+		msg, err := builder.Finish()
+		if err != nil {
+			continue
+		}
+		_, _ = conn.WriteTo(msg, from)
+	}
+}
+
+func TestResolverLookupWithTTL(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error listening for DNS queries: %v", err)
+	}
+	defer conn.Close()
+	go serveDNS(conn, [4]byte{10, 0, 0, 1}, 30)
+
+	resolver := NewResolver(conn.LocalAddr().String(), 5)
+	addrs, ttl, err := resolver.LookupWithTTL("nginx.example.com")
+	if err != nil {
+		t.Fatalf("LookupWithTTL returned an err: %v", err)
+	}
+	if len(addrs) != 1 || addrs[0] != "10.0.0.1" {
+		t.Errorf("LookupWithTTL returned the addresses %v, but [10.0.0.1] expected", addrs)
+	}
+	if ttl != 30*time.Second {
+		t.Errorf("LookupWithTTL returned the TTL %v, but %v expected", ttl, 30*time.Second)
+	}
+}
+
+func TestMinAnswerTTL(t *testing.T) {
+	// Response for example.com with a CNAME (TTL 300) and an A record (TTL 60) using name compression
+	msg := []byte{
+		0x12, 0x34, 0x81, 0x80, 0x00, 0x01, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00,
+		// Question: example.com A IN
+		0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x03, 'c', 'o', 'm', 0x00, 0x00, 0x01, 0x00, 0x01,
+		// Answer 1: CNAME to www.example.com
+		0xc0, 0x0c, 0x00, 0x05, 0x00, 0x01, 0x00, 0x00, 0x01, 0x2c, 0x00, 0x06,
+		0x03, 'w', 'w', 'w', 0xc0, 0x0c,
+		// Answer 2: A 192.0.2.1
+		0xc0, 0x29, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x3c, 0x00, 0x04, 192, 0, 2, 1,
+	}
+
+	testCases := []struct {
+		msg     []byte
+		ttl     uint32
+		wantTTL bool
+		desc    string
+	}{
+		{
+			msg:     msg,
+			ttl:     60,
+			wantTTL: true,
+			desc:    "lowest TTL of all the answers",
+		},
+		{
+			msg:     msg[:29],
+			wantTTL: false,
+			desc:    "answers missing from the message",
+		},
+		{
+			msg:     msg[:50],
+			wantTTL: false,
+			desc:    "truncated answer",
+		},
+		{
+			msg:     msg[:6],
+			wantTTL: false,
+			desc:    "truncated header",
+		},
+	}
+
+	for _, testCase := range testCases {
+		ttl, ok := minAnswerTTL(testCase.msg)
+		if ok != testCase.wantTTL || ttl != testCase.ttl {
+			t.Errorf("minAnswerTTL returned (%v, %v), but (%v, %v) expected for case: %v", ttl, ok, testCase.ttl, testCase.wantTTL, testCase.desc)
+		}
+	}
+}
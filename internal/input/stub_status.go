@@ -0,0 +1,97 @@
+package input
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+)
+
+const (
+	stubStatusActiveConnections = "Active connections:"
+	stubStatusCountersHeader    = "server accepts handled requests"
+)
+
+// StubStatus fetches the data from NGINX instances using the stub_status module.
+// Only the number of active connections and requests are available, so it can only be used with the global method.
+type StubStatus struct {
+	hostsPool
+}
+
+// Fetch gets the stats of the NGINX instances
+func (s *StubStatus) Fetch(ctx context.Context) []*internal.Stats {
+	return s.fetchAll(ctx, getStubStatus)
+}
+
+// Configure sets the configuration of the stub_status clients
+func (s *StubStatus) Configure(cfg *Cfg) error {
+	s.connect = func(instance *Instance) error {
+		// The stub_status page is requested once to check the host is reachable
+		_, err := getStubStatus(context.Background(), instance)
+		return err
+	}
+	return s.configurePool(cfg)
+}
+
+func getStubStatus(ctx context.Context, instance *Instance) (*internal.Stats, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, instance.endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a get request: %w", err)
+	}
+
+	resp, err := instance.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%v is not accessible: %w", instance.endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v is not accessible: expected %v response, got %v", instance.endpoint, http.StatusOK, resp.StatusCode)
+	}
+
+	return parseStubStatus(resp.Body)
+}
+
+// parseStubStatus reads the active connections and the total requests from the output of the stub_status module
+func parseStubStatus(r io.Reader) (*internal.Stats, error) {
+	var stats *internal.Stats
+	countersNext := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, stubStatusActiveConnections):
+			active, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, stubStatusActiveConnections)), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing the active connections of stub_status: %w", err)
+			}
+			stats = &internal.Stats{Connections: active}
+		case line == stubStatusCountersHeader:
+			countersNext = true
+		case countersNext && stats != nil:
+			// The counters are accepts, handled and requests
+			countersNext = false
+			counters := strings.Fields(line)
+			if len(counters) != 3 {
+				return nil, fmt.Errorf("error parsing the counters of stub_status: expected 3 values, got %v", len(counters))
+			}
+			requests, err := strconv.ParseUint(counters[2], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing the requests of stub_status: %w", err)
+			}
+			stats.Requests = requests
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading stub_status: %w", err)
+	}
+	if stats == nil {
+		return nil, fmt.Errorf("active connections not found in stub_status response")
+	}
+	return stats, nil
+}
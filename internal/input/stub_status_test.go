@@ -0,0 +1,137 @@
+package input
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+)
+
+const stubStatusResponse = `Active connections: 291
+server accepts handled requests
+ 16630948 16630948 31070465
+Reading: 6 Writing: 179 Waiting: 106
+`
+
+func TestParseStubStatus(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected *internal.Stats
+		wantErr  bool
+		msg      string
+	}{
+		{
+			input:    stubStatusResponse,
+			expected: &internal.Stats{Connections: 291, Requests: 31070465},
+			msg:      "valid stub_status response",
+		},
+		{
+			input:    "Active connections: 2\n",
+			expected: &internal.Stats{Connections: 2},
+			msg:      "stub_status response without counters",
+		},
+		{
+			input:   "Active connections: 2\nserver accepts handled requests\n 10 10\n",
+			wantErr: true,
+			msg:     "missing counters",
+		},
+		{
+			input:   "Active connections: many\n",
+			wantErr: true,
+			msg:     "active connections not a number",
+		},
+		{
+			input:   "<html>not found</html>",
+			wantErr: true,
+			msg:     "not a stub_status response",
+		},
+	}
+
+	for _, testCase := range testCases {
+		stats, err := parseStubStatus(strings.NewReader(testCase.input))
+		if err == nil && testCase.wantErr {
+			t.Errorf("parseStubStatus err returned <nil>, but an error was expected for case: %v", testCase.msg)
+		}
+		if err != nil && !testCase.wantErr {
+			t.Errorf("parseStubStatus returned an err: %v for case: %v", err, testCase.msg)
+		}
+		if !reflect.DeepEqual(stats, testCase.expected) {
+			t.Errorf("parseStubStatus returned %v, but %v expected for case: %v", stats, testCase.expected, testCase.msg)
+		}
+	}
+}
+
+func TestStubStatusFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/nginx_status" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(stubStatusResponse))
+	}))
+	defer server.Close()
+
+	stubStatus := &StubStatus{}
+	err := stubStatus.Configure(&Cfg{
+		Hosts:         []NginxHost{{Host: server.Listener.Addr().String()}, {Host: server.Listener.Addr().String()}},
+		ClientTimeout: 1,
+		APIEndpoint:   "/nginx_status",
+	})
+	if err != nil {
+		t.Fatalf("StubStatus configuration returned an unexpected error: %v", err)
+	}
+
+	instance := server.Listener.Addr().String()
+	expected := []*internal.Stats{
+		{Instance: instance, Connections: 291, Requests: 31070465},
+		{Instance: instance, Connections: 291, Requests: 31070465},
+	}
+	stats := stubStatus.Fetch(context.Background())
+	if !reflect.DeepEqual(stats, expected) {
+		t.Errorf("StubStatus.Fetch returned %v, but %v expected", stats, expected)
+	}
+}
+
+func TestStubStatusFetchCanceled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Test-Slow") == "" {
+			_, _ = w.Write([]byte(stubStatusResponse))
+			return
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	stubStatus := &StubStatus{}
+	err := stubStatus.Configure(&Cfg{
+		Hosts:         []NginxHost{{Host: server.Listener.Addr().String()}},
+		ClientTimeout: 10,
+	})
+	if err != nil {
+		t.Fatalf("StubStatus configuration returned an unexpected error: %v", err)
+	}
+	// Only the requests done after the configuration are slow
+	stubStatus.ClientsPool[0].httpClient.Transport.(*apiTransport).headers = http.Header{"X-Test-Slow": {"true"}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	stats := stubStatus.Fetch(ctx)
+	if stats != nil {
+		t.Errorf("StubStatus.Fetch returned %v, but nil expected when the context is canceled", stats)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("StubStatus.Fetch didn't return when the context was canceled")
+	}
+}
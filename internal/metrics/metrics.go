@@ -0,0 +1,88 @@
+// Package metrics exposes the telemetry of the agent using the Prometheus client library
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "nginx_ns1_gslb"
+
+// registry holds the metrics of the agent only, so the Handler doesn't expose the ones of the Go runtime
+var registry = prometheus.NewRegistry()
+
+var factory = promauto.With(registry)
+
+// Metrics of the agent
+var (
+	FetchAttempts = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fetch_attempts_total",
+		Help:      "Number of attempts to fetch the stats from an NGINX instance.",
+	}, []string{"host"})
+	FetchFailures = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "fetch_failures_total",
+		Help:      "Number of failed attempts to fetch the stats from an NGINX instance.",
+	}, []string{"host"})
+	FetchBreakerState = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "fetch_circuit_breaker_state",
+		Help:      "State of the circuit breaker of an NGINX instance (0 for closed, 1 for open, 2 for half-open).",
+	}, []string{"host"})
+
+	PushAttempts = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "push_attempts_total",
+		Help:      "Number of attempts to push the data of the feeds to an output.",
+	}, []string{"output"})
+	PushFailures = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "push_failures_total",
+		Help:      "Number of failed attempts to push the data of the feeds to an output.",
+	}, []string{"output"})
+	PushDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "push_duration_seconds",
+		Help:      "Time taken to push the data of the feeds to an output.",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"output"})
+
+	FeedConnections = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "feed_connections",
+		Help:      "Last connections value published for a feed.",
+	}, []string{"feed"})
+	FeedUp = factory.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "feed_up",
+		Help:      "Last up value published for a feed (1 for up, 0 for down).",
+	}, []string{"feed"})
+
+	LoopDuration = factory.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "loop_duration_seconds",
+		Help:      "Time taken by an iteration of the main loop of the agent.",
+		Buckets:   []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60},
+	})
+	LastSuccessfulPush = factory.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_successful_push_timestamp_seconds",
+		Help:      "Unix time of the last successful push of the data of the feeds.",
+	})
+)
+
+// DeleteHost removes the metrics of an NGINX instance that is no longer fetched
+func DeleteHost(host string) {
+	FetchAttempts.DeleteLabelValues(host)
+	FetchFailures.DeleteLabelValues(host)
+	FetchBreakerState.DeleteLabelValues(host)
+}
+
+// Handler returns an http.Handler that writes all the metrics of the agent using the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDeleteHost(t *testing.T) {
+	FetchAttempts.WithLabelValues("delete-test:80").Inc()
+	FetchFailures.WithLabelValues("delete-test:80").Inc()
+	FetchBreakerState.WithLabelValues("delete-test:80").Set(1)
+	FetchAttempts.WithLabelValues("kept-test:80").Inc()
+
+	DeleteHost("delete-test:80")
+
+	testCases := []struct {
+		count    int
+		expected int
+		msg      string
+	}{
+		{
+			count:    testutil.CollectAndCount(FetchAttempts, namespace+"_fetch_attempts_total"),
+			expected: 1,
+			msg:      "fetch attempts of the kept host",
+		},
+		{
+			count:    testutil.CollectAndCount(FetchFailures, namespace+"_fetch_failures_total"),
+			expected: 0,
+			msg:      "fetch failures of the deleted host",
+		},
+		{
+			count:    testutil.CollectAndCount(FetchBreakerState, namespace+"_fetch_circuit_breaker_state"),
+			expected: 0,
+			msg:      "circuit breaker state of the deleted host",
+		},
+	}
+
+	for _, testCase := range testCases {
+		if testCase.count != testCase.expected {
+			t.Errorf("DeleteHost left %v series, but %v expected for case: %v", testCase.count, testCase.expected, testCase.msg)
+		}
+	}
+	FetchAttempts.Reset()
+}
+
+func TestHandler(t *testing.T) {
+	FetchAttempts.WithLabelValues("handler-test:80").Inc()
+	defer FetchAttempts.Reset()
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("Handler returned the content type %v, but text/plain expected", rec.Header().Get("Content-Type"))
+	}
+	expected := `nginx_ns1_gslb_fetch_attempts_total{host="handler-test:80"} 1`
+	if !strings.Contains(rec.Body.String(), expected) {
+		t.Errorf("Handler returned %v, but it was expected to contain %v", rec.Body.String(), expected)
+	}
+}
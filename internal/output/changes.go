@@ -0,0 +1,115 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+)
+
+// changePusher only pushes the feeds whose data changed since they were last pushed, or that were not pushed
+// in the last heartbeat pushes
+type changePusher struct {
+	Pusher
+	threshold float64
+	// percent is true if the threshold is a percentage of the connections last pushed
+	percent   bool
+	heartbeat uint32
+	pushed    map[string]*pushedFeed
+}
+
+// pushedFeed is the data last pushed for a feed and the number of pushes since then
+type pushedFeed struct {
+	data    internal.FeedData
+	skipped uint32
+}
+
+// newChangePusher returns a changePusher for the NS1 configuration
+func newChangePusher(pusher Pusher, cfg *Cfg) (*changePusher, error) {
+	threshold, percent, err := parseChangeThreshold(cfg.ChangeThreshold)
+	if err != nil {
+		return nil, err
+	}
+	return &changePusher{
+		Pusher:    pusher,
+		threshold: threshold,
+		percent:   percent,
+		heartbeat: cfg.HeartbeatIntervals,
+		pushed:    make(map[string]*pushedFeed),
+	}, nil
+}
+
+// parseChangeThreshold returns the threshold of the connections and true if it's a percentage (ends with %)
+func parseChangeThreshold(threshold string) (float64, bool, error) {
+	if threshold == "" {
+		return 0, false, nil
+	}
+
+	value := strings.TrimSpace(threshold)
+	percent := strings.HasSuffix(value, "%")
+	value = strings.TrimSuffix(value, "%")
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || v < 0 {
+		return 0, false, fmt.Errorf("change_threshold [%v] must be a positive number of connections or a percentage", threshold)
+	}
+	return v, percent, nil
+}
+
+// Push sends to the output only the feeds that changed or need a heartbeat. The push is skipped if there are none.
+func (cp *changePusher) Push(ctx context.Context, data map[string]*internal.FeedData) error {
+	changed := make(map[string]*internal.FeedData)
+	for feed, feedData := range data {
+		last, ok := cp.pushed[feed]
+		if !ok || cp.changed(&last.data, feedData) || (cp.heartbeat > 0 && last.skipped+1 >= cp.heartbeat) {
+			changed[feed] = feedData
+		}
+	}
+
+	var err error
+	if len(changed) > 0 {
+		err = cp.Pusher.Push(ctx, changed)
+	} else {
+		log.Printf("None of the feeds changed, the push is skipped")
+	}
+
+	for feed, feedData := range data {
+		last, ok := cp.pushed[feed]
+		if _, pushed := changed[feed]; pushed && err == nil {
+			cp.pushed[feed] = &pushedFeed{data: *feedData}
+		} else if ok {
+			last.skipped++
+		}
+	}
+	return err
+}
+
+// changed returns true if the data of a feed is different enough from the data last pushed
+func (cp *changePusher) changed(last, current *internal.FeedData) bool {
+	return last.Up != current.Up || cp.valueChanged(float64(last.Connections), float64(current.Connections)) ||
+		cp.floatChanged(last.Weight, current.Weight) || cp.floatChanged(last.LoadAvg, current.LoadAvg) ||
+		cp.floatChanged(last.HighWatermark, current.HighWatermark) || cp.floatChanged(last.LowWatermark, current.LowWatermark)
+}
+
+// valueChanged returns true if the difference between the values is greater than the threshold
+func (cp *changePusher) valueChanged(last, current float64) bool {
+	diff := math.Abs(current - last)
+	if cp.percent {
+		if last == 0 {
+			return diff > 0
+		}
+		return diff/math.Abs(last)*100 > cp.threshold
+	}
+	return diff > cp.threshold
+}
+
+// floatChanged works like valueChanged for the metadata, that is always changed if it's added or removed
+func (cp *changePusher) floatChanged(last, current *float64) bool {
+	if last == nil || current == nil {
+		return last != current
+	}
+	return cp.valueChanged(*last, *current)
+}
@@ -0,0 +1,135 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+)
+
+func TestChangePusherPush(t *testing.T) {
+	weight := 2.0
+	lastLoadAvg, loadAvg := 1.5, 1.6
+	testCases := []struct {
+		cfg      *Cfg
+		last     internal.FeedData
+		current  *internal.FeedData
+		skipped  uint32
+		expected bool
+		msg      string
+	}{
+		{
+			cfg:      &Cfg{},
+			last:     internal.FeedData{Connections: 10, Up: true},
+			current:  &internal.FeedData{Connections: 10, Up: true},
+			expected: false,
+			msg:      "same data",
+		},
+		{
+			cfg:      &Cfg{},
+			last:     internal.FeedData{Connections: 10, Up: true},
+			current:  &internal.FeedData{Connections: 11, Up: true},
+			expected: true,
+			msg:      "connections changed without a threshold",
+		},
+		{
+			cfg:      &Cfg{ChangeThreshold: "5"},
+			last:     internal.FeedData{Connections: 10, Up: true},
+			current:  &internal.FeedData{Connections: 15, Up: true},
+			expected: false,
+			msg:      "connections change within the absolute threshold",
+		},
+		{
+			cfg:      &Cfg{ChangeThreshold: "10%"},
+			last:     internal.FeedData{Connections: 100, Up: true},
+			current:  &internal.FeedData{Connections: 89, Up: true},
+			expected: true,
+			msg:      "connections change over the relative threshold",
+		},
+		{
+			cfg:      &Cfg{ChangeThreshold: "10%"},
+			last:     internal.FeedData{Connections: 100, Up: true},
+			current:  &internal.FeedData{Connections: 100, Up: false},
+			expected: true,
+			msg:      "up changed",
+		},
+		{
+			cfg:      &Cfg{},
+			last:     internal.FeedData{Up: true},
+			current:  &internal.FeedData{Up: true, Weight: &weight},
+			expected: true,
+			msg:      "metadata changed",
+		},
+		{
+			cfg:      &Cfg{ChangeThreshold: "10%"},
+			last:     internal.FeedData{Connections: 100, Up: true, LoadAvg: &lastLoadAvg},
+			current:  &internal.FeedData{Connections: 100, Up: true, LoadAvg: &loadAvg},
+			expected: false,
+			msg:      "metadata drift within the relative threshold",
+		},
+		{
+			cfg:      &Cfg{ChangeThreshold: "0.05"},
+			last:     internal.FeedData{Connections: 100, Up: true, LoadAvg: &lastLoadAvg},
+			current:  &internal.FeedData{Connections: 100, Up: true, LoadAvg: &loadAvg},
+			expected: true,
+			msg:      "metadata change over the absolute threshold",
+		},
+		{
+			cfg:      &Cfg{HeartbeatIntervals: 3},
+			last:     internal.FeedData{Connections: 10, Up: true},
+			current:  &internal.FeedData{Connections: 10, Up: true},
+			skipped:  2,
+			expected: true,
+			msg:      "heartbeat",
+		},
+	}
+
+	for _, testCase := range testCases {
+		fp := &fakePusher{}
+		cp, err := newChangePusher(fp, testCase.cfg)
+		if err != nil {
+			t.Fatalf("newChangePusher returned an err: %v for case: %v", err, testCase.msg)
+		}
+		cp.pushed["feed01"] = &pushedFeed{data: testCase.last, skipped: testCase.skipped}
+
+		err = cp.Push(context.Background(), map[string]*internal.FeedData{"feed01": testCase.current})
+		if err != nil {
+			t.Errorf("changePusher.Push returned an err: %v for case: %v", err, testCase.msg)
+		}
+		if pushed := fp.pushed != nil; pushed != testCase.expected {
+			t.Errorf("changePusher.Push pushed the feed: %v, but %v expected for case: %v", pushed, testCase.expected, testCase.msg)
+		}
+	}
+}
+
+func TestChangePusherPushFailed(t *testing.T) {
+	fp := &fakePusher{err: errors.New("push error")}
+	cp, _ := newChangePusher(fp, &Cfg{})
+	data := map[string]*internal.FeedData{"feed01": {Connections: 1, Up: true}}
+
+	if err := cp.Push(context.Background(), data); err == nil {
+		t.Errorf("changePusher.Push err returned <nil>, but an error was expected")
+	}
+
+	// The feeds that failed are pushed again even if they didn't change
+	fp.err = nil
+	fp.pushed = nil
+	if err := cp.Push(context.Background(), data); err != nil {
+		t.Errorf("changePusher.Push returned an err: %v", err)
+	}
+	if !reflect.DeepEqual(fp.pushed, data) {
+		t.Errorf("changePusher.Push pushed %v, but %v expected after a failed push", fp.pushed, data)
+	}
+}
+
+func TestParseChangeThreshold(t *testing.T) {
+	if _, _, err := parseChangeThreshold("-5"); err == nil {
+		t.Errorf("parseChangeThreshold err returned <nil>, but an error was expected for a negative threshold")
+	}
+	threshold, percent, err := parseChangeThreshold("2.5%")
+	if err != nil || threshold != 2.5 || !percent {
+		t.Errorf("parseChangeThreshold returned %v, %v, %v, but 2.5, true, <nil> expected", threshold, percent, err)
+	}
+}
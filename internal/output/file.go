@@ -0,0 +1,65 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+)
+
+// File writes the data of the feeds as JSON lines to a file or to the standard output.
+// It is useful to check what data would be sent to other outputs like NS1.
+type File struct {
+	writer io.Writer
+	path   string
+}
+
+// Configure sets the destination of the data. If the type is stdout (or the path is "-") the data is written to the standard output.
+func (f *File) Configure(cfg *SinkCfg) error {
+	if cfg.Type == StdoutType || cfg.Path == "-" {
+		f.writer = os.Stdout
+		f.path = StdoutType
+		return nil
+	}
+
+	if cfg.Path == "" {
+		return fmt.Errorf("the file output requires a path to be defined")
+	}
+
+	file, err := os.OpenFile(cfg.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("error opening file at %v: %w", cfg.Path, err)
+	}
+	f.writer = file
+	f.path = cfg.Path
+	return nil
+}
+
+// Push writes the data as a single JSON line
+func (f *File) Push(_ context.Context, data map[string]*internal.FeedData) error {
+	line, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error encoding the data: %w", err)
+	}
+
+	log.Printf("Writing data to %v", f.path)
+	_, err = f.writer.Write(append(line, '\n'))
+	return err
+}
+
+// ValidateFeeds accepts any feed name
+func (f *File) ValidateFeeds(_ []string) error {
+	return nil
+}
+
+// Close closes the file. The standard output is not closed.
+func (f *File) Close() error {
+	if file, ok := f.writer.(*os.File); ok && file != os.Stdout {
+		return file.Close()
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package output
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+)
+
+func TestFilePush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feeds.json")
+	file := &File{}
+	if err := file.Configure(&SinkCfg{Type: FileType, Path: path}); err != nil {
+		t.Fatalf("File configuration returned an unexpected error: %v", err)
+	}
+
+	data := map[string]*internal.FeedData{
+		"feed01": {Connections: 10, Up: true},
+		"feed02": {Up: false},
+	}
+	for i := 0; i < 2; i++ {
+		if err := file.Push(context.Background(), data); err != nil {
+			t.Fatalf("File.Push returned an unexpected error: %v", err)
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error reading the output file: %v", err)
+	}
+	line := `{"feed01":{"connections":10,"up":true},"feed02":{"up":false}}` + "\n"
+	expected := line + line
+	if string(content) != expected {
+		t.Errorf("File.Push wrote %q, but %q expected", content, expected)
+	}
+}
+
+func TestFileClose(t *testing.T) {
+	file := &File{}
+	if err := file.Configure(&SinkCfg{Type: FileType, Path: filepath.Join(t.TempDir(), "feeds.json")}); err != nil {
+		t.Fatalf("File configuration returned an unexpected error: %v", err)
+	}
+
+	if err := file.Close(); err != nil {
+		t.Errorf("File.Close returned an unexpected error: %v", err)
+	}
+	if err := file.Push(context.Background(), map[string]*internal.FeedData{"feed01": {Up: true}}); err == nil {
+		t.Errorf("File.Push err returned <nil>, but an error was expected after the file was closed")
+	}
+
+	stdout := &File{}
+	if err := stdout.Configure(&SinkCfg{Type: StdoutType}); err != nil {
+		t.Fatalf("File configuration returned an unexpected error: %v", err)
+	}
+	if err := stdout.Close(); err != nil {
+		t.Errorf("File.Close returned an unexpected error: %v for the standard output", err)
+	}
+}
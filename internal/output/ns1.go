@@ -1,19 +1,59 @@
 package output
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/nginxinc/nginx-ns1-gslb/internal"
 	api "gopkg.in/ns1/ns1-go.v2/rest"
+	"gopkg.in/ns1/ns1-go.v2/rest/model/data"
 )
 
-// Feed contains all the information related one single Feed for the NS1 API call
+// Feed contains all the information related one single Feed for the NS1 API call.
+// The method, threshold, sampling type and protocol override the ones of the services for this feed.
 type Feed struct {
-	Name     string `yaml:"name"`
-	FeedName string `yaml:"feed_name"`
+	Name         string        `yaml:"name"`
+	FeedName     string        `yaml:"feed_name"`
+	Method       string        `yaml:"method"`
+	Threshold    *uint         `yaml:"threshold"`
+	SamplingType string        `yaml:"sampling_type"`
+	Protocol     string        `yaml:"protocol"`
+	Metadata     *FeedMetadata `yaml:"metadata"`
+	Policy       *FeedPolicy   `yaml:"policy"`
+	// MinHealthyInstances is the number of NGINX instances, or the percentage if it ends with %, that must be fetched
+	// for the data of the feed to be published. BelowQuorum is the action when they are not: "down" or "degraded"
+	MinHealthyInstances string `yaml:"min_healthy_instances"`
+	BelowQuorum         string `yaml:"below_quorum"`
+	// Targets are the names of the NS1 targets the feed is published to. Only the default target if empty
+	Targets []string `yaml:"targets"`
+}
+
+// FeedMetadata maps the stats of NGINX Plus to the metadata of a feed used by the NS1 filters
+type FeedMetadata struct {
+	// Weight and LoadAvg are the names of the stats published as the weight and loadavg of the feed
+	Weight  string `yaml:"weight"`
+	LoadAvg string `yaml:"loadavg"`
+	// HighWatermark and LowWatermark are published as they are
+	HighWatermark *float64 `yaml:"high_watermark"`
+	LowWatermark  *float64 `yaml:"low_watermark"`
+}
+
+// FeedPolicy defines when the NGINX resource of a feed is considered up. A value of 0 disables the check.
+type FeedPolicy struct {
+	// HealthyStates are the states of the peers considered healthy. Only "up" by default
+	HealthyStates []string `yaml:"healthy_states"`
+	// MinHealthyPercent is the minimum percentage of healthy peers
+	MinHealthyPercent float64 `yaml:"min_healthy_percent"`
+	// MaxErrorsRatio is the maximum percentage of responses with a 5xx status since the previous cycle
+	MaxErrorsRatio float64 `yaml:"max_errors_ratio"`
+	// MaxResponseTime is the maximum average response time in milliseconds of the healthy peers
+	MaxResponseTime uint64 `yaml:"max_response_time"`
 }
 
 // Cfg stores the configuration parameters for NS1
@@ -21,25 +61,51 @@ type Cfg struct {
 	APIKey        string `yaml:"api_key"`
 	ClientTimeout int    `yaml:"client_timeout"`
 	SourceID      string `yaml:"source_id"`
+	// PublishOnChange only publishes the feeds whose connections or metadata changed more than the ChangeThreshold
+	// (absolute value, or percentage of the value last published if it ends with %), or that were not published in
+	// the last HeartbeatIntervals intervals
+	PublishOnChange    bool   `yaml:"publish_on_change"`
+	ChangeThreshold    string `yaml:"change_threshold"`
+	HeartbeatIntervals uint32 `yaml:"heartbeat_intervals"`
+	// Targets are other NS1 data sources, of the same or other accounts, the feeds can be published to
+	Targets []TargetCfg `yaml:"targets"`
+	// CreateMissingFeeds creates the feeds not found in the data sources with the FeedConfig, where {feed_name} is
+	// replaced by the feed name. ReportUnusedFeeds logs the feeds of the data sources that are not used by the agent
+	CreateMissingFeeds bool              `yaml:"create_missing_feeds"`
+	FeedConfig         map[string]string `yaml:"feed_config"`
+	ReportUnusedFeeds  bool              `yaml:"report_unused_feeds"`
 }
 
+// FeedNamePlaceholder is replaced by the feed name in the values of the config of the feeds created in NS1
+const FeedNamePlaceholder = "{feed_name}"
+
 // NS1 stores the NSONE API client and some internal configuration to send the data to NSONE
 type NS1 struct {
 	Cfg    *Cfg
 	client *api.Client
 }
 
-// Push will send the data to the NSONE API
-func (ns1 *NS1) Push(data map[string]*internal.FeedData) error {
+// Push will send the data to the NSONE API. The request is aborted if ctx is canceled.
+func (ns1 *NS1) Push(ctx context.Context, data map[string]*internal.FeedData) error {
 	if len(data) == 0 {
 		return fmt.Errorf("there is no data to send")
 	}
 
 	log.Printf("Pushing data to NS1")
 
+	// The request is built like DataSources.Publish does, so the context can be set
+	req, err := ns1.client.NewRequest(http.MethodPost, fmt.Sprintf("feed/%s", ns1.Cfg.SourceID), &data)
+	if err != nil {
+		return err
+	}
+
 	// _ is the http.Response object. We don't need it here as the API does not return anything meaningful
-	_, err := ns1.client.DataSources.Publish(ns1.Cfg.SourceID, data)
+	_, err = ns1.client.Do(req.WithContext(ctx), nil)
 
+	var restErr *api.Error
+	if errors.As(err, &restErr) {
+		return newHTTPPushError(err, restErr.Resp)
+	}
 	return err
 }
 
@@ -59,6 +125,59 @@ func (ns1 *NS1) Configure(cfg *Cfg) error {
 	return nil
 }
 
+// ValidateFeeds checks that all the feed names exist in the NS1 data source, creating the missing ones if
+// create_missing_feeds is enabled
+func (ns1 *NS1) ValidateFeeds(feedNames []string) error {
+	feeds, err := ns1.GetFeedsForSourceID(ns1.Cfg.SourceID)
+	if err != nil {
+		return fmt.Errorf("error trying to get Feeds from NS1 for validation: %w", err)
+	}
+
+	used := make(map[string]bool, len(feedNames))
+	for _, name := range feedNames {
+		used[name] = true
+		if _, ok := feeds[name]; ok {
+			continue
+		}
+		if !ns1.Cfg.CreateMissingFeeds {
+			return fmt.Errorf("feed Name %v not found in NS1 DataFeed with source = %v. Review NS1 configuration", name, ns1.Cfg.SourceID)
+		}
+		if err := ns1.createFeed(name); err != nil {
+			return fmt.Errorf("error creating feed %v in NS1 DataFeed with source = %v: %w", name, ns1.Cfg.SourceID, err)
+		}
+		log.Printf("Feed %v created in NS1 DataFeed with source = %v", name, ns1.Cfg.SourceID)
+	}
+
+	if ns1.Cfg.ReportUnusedFeeds {
+		var unused []string
+		for name := range feeds {
+			if !used[name] {
+				unused = append(unused, name)
+			}
+		}
+		sort.Strings(unused)
+		if len(unused) > 0 {
+			log.Printf("%d feeds of NS1 DataFeed with source = %v are not used by the agent: %v", len(unused), ns1.Cfg.SourceID, strings.Join(unused, ", "))
+		}
+	}
+	return nil
+}
+
+// createFeed creates the feed in the NS1 data source with the feed config
+func (ns1 *NS1) createFeed(name string) error {
+	cfg := make(data.Config, len(ns1.Cfg.FeedConfig))
+	for key, value := range ns1.Cfg.FeedConfig {
+		cfg[key] = strings.ReplaceAll(value, FeedNamePlaceholder, name)
+	}
+	_, err := ns1.client.DataFeeds.Create(ns1.Cfg.SourceID, data.NewFeed(name, cfg))
+	return err
+}
+
+// Close does nothing, the NS1 client doesn't hold any resource
+func (ns1 *NS1) Close() error {
+	return nil
+}
+
 // GetFeedsForSourceID returns a map with all the feed names as keys for future checks
 func (ns1 NS1) GetFeedsForSourceID(sourceID string) (map[string]bool, error) {
 	feeds, _, err := ns1.client.DataFeeds.List(sourceID)
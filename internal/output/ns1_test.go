@@ -1,7 +1,13 @@
 package output
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"testing"
+
+	api "gopkg.in/ns1/ns1-go.v2/rest"
 )
 
 func TestConfigureNs1(t *testing.T) {
@@ -28,3 +34,83 @@ func TestConfigureNs1(t *testing.T) {
 		}
 	}
 }
+
+func TestNs1ValidateFeeds(t *testing.T) {
+	testCases := []struct {
+		cfg             *Cfg
+		feedNames       []string
+		wantErr         bool
+		expectedCreated map[string]map[string]string
+		msg             string
+	}{
+		{
+			cfg:       &Cfg{APIKey: "key", SourceID: "source"},
+			feedNames: []string{"feed01"},
+			wantErr:   false,
+			msg:       "existing feeds",
+		},
+		{
+			cfg:       &Cfg{APIKey: "key", SourceID: "source"},
+			feedNames: []string{"feed01", "feed02"},
+			wantErr:   true,
+			msg:       "missing feed",
+		},
+		{
+			cfg: &Cfg{
+				APIKey:             "key",
+				SourceID:           "source",
+				CreateMissingFeeds: true,
+				FeedConfig:         map[string]string{"label": FeedNamePlaceholder, "region": "eu-" + FeedNamePlaceholder},
+				ReportUnusedFeeds:  true,
+			},
+			feedNames:       []string{"feed01", "feed02"},
+			wantErr:         false,
+			expectedCreated: map[string]map[string]string{"feed02": {"label": "feed02", "region": "eu-feed02"}},
+			msg:             "missing feed created",
+		},
+	}
+
+	for _, testCase := range testCases {
+		created := make(map[string]map[string]string)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/v1/data/feeds/source" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.Method == http.MethodPut {
+				var feed struct {
+					Name   string            `json:"name"`
+					Config map[string]string `json:"config"`
+				}
+				if err := json.NewDecoder(r.Body).Decode(&feed); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				created[feed.Name] = feed.Config
+				_, _ = w.Write([]byte("{}"))
+				return
+			}
+			_, _ = w.Write([]byte(`[{"name": "feed01"}, {"name": "unused"}]`))
+		}))
+
+		ns1 := &NS1{}
+		if err := ns1.Configure(testCase.cfg); err != nil {
+			t.Fatalf("NS1 configuration returned an unexpected error: %v", err)
+		}
+		ns1.client = api.NewClient(server.Client(), api.SetEndpoint(server.URL+"/v1/"))
+
+		err := ns1.ValidateFeeds(testCase.feedNames)
+		if err == nil && testCase.wantErr {
+			t.Errorf("NS1.ValidateFeeds err returned <nil>, but an error was expected for case: %v", testCase.msg)
+		}
+		if err != nil && !testCase.wantErr {
+			t.Errorf("NS1.ValidateFeeds returned an err: %v for case: %v", err, testCase.msg)
+		}
+		if len(created) > 0 || len(testCase.expectedCreated) > 0 {
+			if !reflect.DeepEqual(created, testCase.expectedCreated) {
+				t.Errorf("NS1.ValidateFeeds created %v, but %v expected for case: %v", created, testCase.expectedCreated, testCase.msg)
+			}
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,191 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+	"github.com/nginxinc/nginx-ns1-gslb/internal/metrics"
+)
+
+// Types of outputs
+const (
+	NS1Type     = "nsone"
+	StdoutType  = "stdout"
+	FileType    = "file"
+	WebhookType = "webhook"
+)
+
+// Pusher is implemented by all the outputs the agent can send the data of the feeds to
+type Pusher interface {
+	// Push sends the data of the feeds to the output
+	Push(ctx context.Context, data map[string]*internal.FeedData) error
+	// ValidateFeeds checks that the output is able to receive the data for all the feed names
+	ValidateFeeds(feedNames []string) error
+	// Close releases the resources of the output. The Pusher can't be used afterwards
+	Close() error
+}
+
+// SinkCfg stores the configuration of one of the outputs the agent will push the data to.
+// The nsone type uses the parameters of the NSONE API configuration.
+type SinkCfg struct {
+	Type          string            `yaml:"type"`
+	Path          string            `yaml:"path"`
+	URL           string            `yaml:"url"`
+	Headers       map[string]string `yaml:"headers"`
+	ClientTimeout int               `yaml:"client_timeout"`
+}
+
+// Validate checks the parameters required by the type of output are defined
+func (s *SinkCfg) Validate(nsoneCfg *Cfg) error {
+	switch s.Type {
+	case NS1Type:
+		if err := nsoneCfg.targetsCfgError(); err != nil {
+			return err
+		}
+		if _, _, err := parseChangeThreshold(nsoneCfg.ChangeThreshold); err != nil {
+			return err
+		}
+		if len(nsoneCfg.FeedConfig) > 0 && !nsoneCfg.CreateMissingFeeds {
+			return fmt.Errorf("feed_config is only used with create_missing_feeds")
+		}
+	case StdoutType:
+	case FileType:
+		if s.Path == "" {
+			return fmt.Errorf("the %v output requires a path to be defined", FileType)
+		}
+	case WebhookType:
+		u, err := url.Parse(s.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("the %v output requires a valid http or https url, got [%v]", WebhookType, s.URL)
+		}
+	default:
+		return fmt.Errorf("%v is not a valid output type. Valid types are: %v, %v, %v, %v", s.Type, NS1Type, StdoutType, FileType, WebhookType)
+	}
+
+	if s.ClientTimeout < 0 {
+		return fmt.Errorf("client_timeout can't be negative")
+	}
+	return nil
+}
+
+// New creates and configures the outputs defined in sinks. If more than one output is defined, the returned Pusher
+// will push the data to all of them. If retryCfg is not nil, the pushes to every output are retried independently.
+// The nsone output pushes the data of every feed to its NS1 targets. If publish_on_change is enabled, only the feeds
+// that changed are pushed to them.
+func New(sinks []SinkCfg, nsoneCfg *Cfg, retryCfg *RetryCfg, feeds []Feed) (Pusher, error) {
+	if len(sinks) == 0 {
+		return nil, fmt.Errorf("at least 1 output needs to be defined")
+	}
+
+	var pushers multiPusher
+	for i := range sinks {
+		var pusher Pusher
+		var err error
+		if sinks[i].Type == NS1Type {
+			pusher, err = newNS1Pusher(nsoneCfg, retryCfg, feeds)
+		} else {
+			pusher, err = newPusher(&sinks[i])
+			if err == nil {
+				pusher, err = wrapPusher(pusher, sinks[i].Type, retryCfg, nil)
+			}
+		}
+		if err != nil {
+			// The outputs already configured are not used
+			_ = pushers.Close()
+			return nil, fmt.Errorf("error configuring output %v: %w", sinks[i].Type, err)
+		}
+		pushers = append(pushers, pusher)
+	}
+
+	if len(pushers) == 1 {
+		return pushers[0], nil
+	}
+	return pushers, nil
+}
+
+// wrapPusher adds the metrics and the retries to the pusher of an output, and the change detection if nsoneCfg enables it
+func wrapPusher(pusher Pusher, output string, retryCfg *RetryCfg, nsoneCfg *Cfg) (Pusher, error) {
+	pusher = &measuredPusher{Pusher: pusher, output: output}
+	if retryCfg != nil && retryCfg.Retries > 0 {
+		pusher = &retryPusher{Pusher: pusher, cfg: retryCfg, output: output}
+	}
+	if nsoneCfg != nil && nsoneCfg.PublishOnChange {
+		return newChangePusher(pusher, nsoneCfg)
+	}
+	return pusher, nil
+}
+
+func newPusher(sink *SinkCfg) (Pusher, error) {
+	switch sink.Type {
+	case StdoutType, FileType:
+		file := &File{}
+		return file, file.Configure(sink)
+	case WebhookType:
+		webhook := &Webhook{}
+		return webhook, webhook.Configure(sink)
+	}
+	return nil, fmt.Errorf("%v is not a valid output type. Valid types are: %v, %v, %v, %v", sink.Type, NS1Type, StdoutType, FileType, WebhookType)
+}
+
+// measuredPusher records the attempts, failures and latency of the pushes to an output
+type measuredPusher struct {
+	Pusher
+	output string
+}
+
+// Push sends the data to the output updating its metrics
+func (mp *measuredPusher) Push(ctx context.Context, data map[string]*internal.FeedData) error {
+	start := time.Now()
+	err := mp.Pusher.Push(ctx, data)
+	metrics.PushDuration.WithLabelValues(mp.output).Observe(time.Since(start).Seconds())
+	metrics.PushAttempts.WithLabelValues(mp.output).Inc()
+	if err != nil {
+		metrics.PushFailures.WithLabelValues(mp.output).Inc()
+	}
+	return err
+}
+
+// multiPusher pushes the data to several outputs at the same time
+type multiPusher []Pusher
+
+// Push sends the data to all the outputs. An error in one of the outputs does not prevent sending the data to the rest.
+func (mp multiPusher) Push(ctx context.Context, data map[string]*internal.FeedData) error {
+	var errs []string
+	for _, pusher := range mp {
+		if err := pusher.Push(ctx, data); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error pushing to %d of %d outputs: %v", len(errs), len(mp), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ValidateFeeds checks the feed names in all the outputs
+func (mp multiPusher) ValidateFeeds(feedNames []string) error {
+	for _, pusher := range mp {
+		if err := pusher.ValidateFeeds(feedNames); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes all the outputs
+func (mp multiPusher) Close() error {
+	var errs []string
+	for _, pusher := range mp {
+		if err := pusher.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error closing %d of %d outputs: %v", len(errs), len(mp), strings.Join(errs, "; "))
+	}
+	return nil
+}
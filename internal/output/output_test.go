@@ -0,0 +1,106 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+)
+
+func TestNew(t *testing.T) {
+	testCases := []struct {
+		sinks   []SinkCfg
+		wantErr bool
+		msg     string
+	}{
+		{
+			sinks:   nil,
+			wantErr: true,
+			msg:     "no outputs",
+		},
+		{
+			sinks:   []SinkCfg{{Type: "unknown"}},
+			wantErr: true,
+			msg:     "wrong output type",
+		},
+		{
+			sinks:   []SinkCfg{{Type: NS1Type}},
+			wantErr: true,
+			msg:     "nsone output with missing API KEY",
+		},
+		{
+			sinks:   []SinkCfg{{Type: FileType}},
+			wantErr: true,
+			msg:     "file output with missing path",
+		},
+		{
+			sinks:   []SinkCfg{{Type: WebhookType, URL: "ftp://example.com"}},
+			wantErr: true,
+			msg:     "webhook output with wrong url",
+		},
+		{
+			sinks:   []SinkCfg{{Type: StdoutType}},
+			wantErr: false,
+			msg:     "single output",
+		},
+		{
+			sinks: []SinkCfg{
+				{Type: StdoutType},
+				{Type: FileType, Path: filepath.Join(t.TempDir(), "feeds.json")},
+				{Type: WebhookType, URL: "http://example.com/feeds"},
+			},
+			wantErr: false,
+			msg:     "multiple outputs",
+		},
+	}
+
+	for _, testCase := range testCases {
+		_, err := New(testCase.sinks, &Cfg{}, nil, nil)
+		if err == nil && testCase.wantErr {
+			t.Errorf("New err returned <nil>, but an error was expected for case: %v", testCase.msg)
+		}
+		if err != nil && !testCase.wantErr {
+			t.Errorf("New returned an err: %v for case: %v", err, testCase.msg)
+		}
+	}
+}
+
+// fakePusher is a Pusher that stores the pushed data and returns the configured error
+type fakePusher struct {
+	pushed map[string]*internal.FeedData
+	err    error
+}
+
+func (fp *fakePusher) Push(_ context.Context, data map[string]*internal.FeedData) error {
+	fp.pushed = data
+	return fp.err
+}
+
+func (fp *fakePusher) ValidateFeeds(_ []string) error {
+	return fp.err
+}
+
+func (fp *fakePusher) Close() error {
+	return nil
+}
+
+func TestMultiPusherPush(t *testing.T) {
+	failing := &fakePusher{err: errors.New("push error")}
+	working := &fakePusher{}
+	mp := multiPusher{failing, working}
+
+	data := map[string]*internal.FeedData{"feed01": {Connections: 1, Up: true}}
+	err := mp.Push(context.Background(), data)
+	if err == nil {
+		t.Errorf("multiPusher.Push err returned <nil>, but an error was expected because one of the outputs failed")
+	}
+	if working.pushed == nil {
+		t.Errorf("multiPusher.Push didn't push the data to all the outputs when one of them failed")
+	}
+
+	if err := mp.ValidateFeeds([]string{"feed01"}); err == nil {
+		t.Errorf("multiPusher.ValidateFeeds err returned <nil>, but an error was expected because one of the outputs failed")
+	}
+}
@@ -0,0 +1,150 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+)
+
+// maxRetryBackoff is the max time to wait between two retries of a push, not including the time requested by the output
+const maxRetryBackoff = time.Minute
+
+// NS1 rate limit headers
+const (
+	headerRateLimit  = "X-Ratelimit-Limit"
+	headerRatePeriod = "X-Ratelimit-Period"
+)
+
+// RetryCfg stores the parameters to retry the pushes that fail
+type RetryCfg struct {
+	// Retries is the max number of retries after the first attempt
+	Retries uint32
+	// Backoff is the time to wait before the first retry, doubled on every retry
+	Backoff time.Duration
+	// MaxDuration is the max time since the first attempt of a push to start a retry. 0 doesn't limit it
+	MaxDuration time.Duration
+}
+
+// PushError is returned by the outputs when the data can't be pushed, to tell whether the push can be retried.
+// Errors of any other type are considered transient.
+type PushError struct {
+	Err error
+	// Permanent is true if the push will fail again if retried (eg: the request is not valid)
+	Permanent bool
+	// RetryAfter is the min time to wait before retrying requested by the output (eg: when it's rate limited)
+	RetryAfter time.Duration
+}
+
+func (e *PushError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PushError) Unwrap() error {
+	return e.Err
+}
+
+// newHTTPPushError classifies the error of a push depending on the status code of the response.
+// 408, 429 and 5xx responses can be retried, the rest are permanent errors.
+func newHTTPPushError(err error, resp *http.Response) *PushError {
+	pushErr := &PushError{Err: err}
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		pushErr.RetryAfter = retryAfter(resp.Header)
+	case resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode >= http.StatusInternalServerError:
+	default:
+		pushErr.Permanent = true
+	}
+	return pushErr
+}
+
+// retryAfter returns the time to wait before retrying a rate limited request, using the Retry-After header or
+// the NS1 rate limit headers. NS1 replenishes the requests of the limit evenly during the period.
+func retryAfter(header http.Header) time.Duration {
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	limit, err := strconv.Atoi(header.Get(headerRateLimit))
+	if err != nil || limit <= 0 {
+		return 0
+	}
+	period, err := strconv.Atoi(header.Get(headerRatePeriod))
+	if err != nil || period <= 0 {
+		return 0
+	}
+	return time.Duration(period) * time.Second / time.Duration(limit)
+}
+
+// retryPusher retries the pushes to an output using exponential backoff with jitter.
+// The retries stop when the next one would start after the max duration of the push or the deadline of ctx.
+// The first attempt is always done.
+type retryPusher struct {
+	Pusher
+	cfg    *RetryCfg
+	output string
+}
+
+// Push sends the data to the output, retrying if it fails with a transient error
+func (rp *retryPusher) Push(ctx context.Context, data map[string]*internal.FeedData) error {
+	start := time.Now()
+	backoff := rp.cfg.Backoff
+	for retry := uint32(0); ; retry++ {
+		err := rp.Pusher.Push(ctx, data)
+		if err == nil || retry >= rp.cfg.Retries || ctx.Err() != nil {
+			return err
+		}
+
+		var pushErr *PushError
+		if errors.As(err, &pushErr) && pushErr.Permanent {
+			return err
+		}
+
+		wait := withJitter(backoff)
+		if pushErr != nil && pushErr.RetryAfter > wait {
+			wait = pushErr.RetryAfter
+		}
+		if rp.cfg.MaxDuration > 0 && time.Since(start)+wait > rp.cfg.MaxDuration {
+			return fmt.Errorf("%w (not retried, the next retry would exceed the max duration of the push)", err)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return fmt.Errorf("%w (not retried, the next retry would exceed the deadline of the push)", err)
+		}
+
+		log.Printf("error pushing the data to the %v output, retrying in %v (%d/%d): %v", rp.output, wait.Round(time.Millisecond), retry+1, rp.cfg.Retries, err)
+		if !sleep(ctx, wait) {
+			return err
+		}
+
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// withJitter returns a random duration between d/2 and d, so the retries of several agents are spread
+func withJitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2))) // #nosec G404
+}
+
+// sleep waits for the duration d. It returns false if ctx is canceled before.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,154 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+)
+
+// sequencePusher returns the errors in order, one per push, and succeeds once all of them have been returned
+type sequencePusher struct {
+	errs     []error
+	attempts int
+}
+
+func (sp *sequencePusher) Push(_ context.Context, _ map[string]*internal.FeedData) error {
+	sp.attempts++
+	if sp.attempts <= len(sp.errs) {
+		return sp.errs[sp.attempts-1]
+	}
+	return nil
+}
+
+func (sp *sequencePusher) ValidateFeeds(_ []string) error {
+	return nil
+}
+
+func (sp *sequencePusher) Close() error {
+	return nil
+}
+
+func TestRetryPusherPush(t *testing.T) {
+	transientErr := errors.New("connection reset")
+	testCases := []struct {
+		errs             []error
+		retries          uint32
+		maxDuration      time.Duration
+		timeout          time.Duration
+		expectedAttempts int
+		wantErr          bool
+		msg              string
+	}{
+		{
+			errs:             []error{transientErr, &PushError{Err: errors.New("503")}},
+			retries:          3,
+			expectedAttempts: 3,
+			wantErr:          false,
+			msg:              "push succeeds after transient errors",
+		},
+		{
+			errs:             []error{transientErr, transientErr, transientErr},
+			retries:          2,
+			expectedAttempts: 3,
+			wantErr:          true,
+			msg:              "retries exhausted",
+		},
+		{
+			errs:             []error{&PushError{Err: errors.New("400"), Permanent: true}},
+			retries:          3,
+			expectedAttempts: 1,
+			wantErr:          true,
+			msg:              "permanent errors are not retried",
+		},
+		{
+			errs:             []error{&PushError{Err: errors.New("429"), RetryAfter: time.Minute}},
+			retries:          3,
+			timeout:          time.Second,
+			expectedAttempts: 1,
+			wantErr:          true,
+			msg:              "retry after the deadline is not done",
+		},
+		{
+			errs:             []error{&PushError{Err: errors.New("429"), RetryAfter: time.Minute}},
+			retries:          3,
+			maxDuration:      time.Second,
+			expectedAttempts: 1,
+			wantErr:          true,
+			msg:              "retry after the max duration is not done",
+		},
+	}
+
+	for _, testCase := range testCases {
+		ctx := context.Background()
+		if testCase.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, testCase.timeout)
+			defer cancel()
+		}
+
+		sp := &sequencePusher{errs: testCase.errs}
+		rp := &retryPusher{Pusher: sp, cfg: &RetryCfg{Retries: testCase.retries, Backoff: time.Millisecond, MaxDuration: testCase.maxDuration}, output: "test"}
+		err := rp.Push(ctx, map[string]*internal.FeedData{"feed01": {Up: true}})
+		if err == nil && testCase.wantErr {
+			t.Errorf("retryPusher.Push err returned <nil>, but an error was expected for case: %v", testCase.msg)
+		}
+		if err != nil && !testCase.wantErr {
+			t.Errorf("retryPusher.Push returned an err: %v for case: %v", err, testCase.msg)
+		}
+		if sp.attempts != testCase.expectedAttempts {
+			t.Errorf("retryPusher.Push made %v attempts, but %v expected for case: %v", sp.attempts, testCase.expectedAttempts, testCase.msg)
+		}
+	}
+}
+
+func TestNewHTTPPushError(t *testing.T) {
+	testCases := []struct {
+		code       int
+		header     http.Header
+		permanent  bool
+		retryAfter time.Duration
+		msg        string
+	}{
+		{
+			code:      http.StatusBadRequest,
+			permanent: true,
+			msg:       "bad request is permanent",
+		},
+		{
+			code:      http.StatusBadGateway,
+			permanent: false,
+			msg:       "server error can be retried",
+		},
+		{
+			code:       http.StatusTooManyRequests,
+			header:     http.Header{"Retry-After": []string{"3"}},
+			retryAfter: 3 * time.Second,
+			msg:        "rate limited with Retry-After",
+		},
+		{
+			code:       http.StatusTooManyRequests,
+			header:     http.Header{headerRateLimit: []string{"10"}, headerRatePeriod: []string{"5"}},
+			retryAfter: 500 * time.Millisecond,
+			msg:        "rate limited with NS1 headers",
+		},
+		{
+			code:       http.StatusTooManyRequests,
+			retryAfter: 0,
+			msg:        "rate limited without headers",
+		},
+	}
+
+	for _, testCase := range testCases {
+		pushErr := newHTTPPushError(errors.New("push error"), &http.Response{StatusCode: testCase.code, Header: testCase.header})
+		if pushErr.Permanent != testCase.permanent {
+			t.Errorf("newHTTPPushError returned Permanent=%v, but %v expected for case: %v", pushErr.Permanent, testCase.permanent, testCase.msg)
+		}
+		if pushErr.RetryAfter != testCase.retryAfter {
+			t.Errorf("newHTTPPushError returned RetryAfter=%v, but %v expected for case: %v", pushErr.RetryAfter, testCase.retryAfter, testCase.msg)
+		}
+	}
+}
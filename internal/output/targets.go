@@ -0,0 +1,229 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+)
+
+// DefaultTarget is the name of the NS1 target defined by the api_key and source_id of the nsone section
+const DefaultTarget = "default"
+
+// TargetCfg stores the configuration of a named NS1 data source the feeds can be published to.
+// The client timeout of the nsone section is used if not defined.
+type TargetCfg struct {
+	Name          string `yaml:"name"`
+	APIKey        string `yaml:"api_key"`
+	ClientTimeout int    `yaml:"client_timeout"`
+	SourceID      string `yaml:"source_id"`
+}
+
+// HasTarget returns true if the NS1 target is defined
+func (cfg *Cfg) HasTarget(name string) bool {
+	_, ok := cfg.targetCfgs()[name]
+	return ok
+}
+
+// targetCfgs returns the NS1 configuration of every target by name. The targets share the publish_on_change parameters.
+func (cfg *Cfg) targetCfgs() map[string]*Cfg {
+	cfgs := make(map[string]*Cfg, len(cfg.Targets)+1)
+	if cfg.APIKey != "" || cfg.SourceID != "" {
+		defaultCfg := *cfg
+		defaultCfg.Targets = nil
+		cfgs[DefaultTarget] = &defaultCfg
+	}
+	for _, target := range cfg.Targets {
+		targetCfg := *cfg
+		targetCfg.APIKey = target.APIKey
+		targetCfg.SourceID = target.SourceID
+		if target.ClientTimeout != 0 {
+			targetCfg.ClientTimeout = target.ClientTimeout
+		}
+		targetCfg.Targets = nil
+		cfgs[target.Name] = &targetCfg
+	}
+	return cfgs
+}
+
+// targetsCfgError checks that at least one NS1 target is defined and all of them have a unique name, an API key and a source ID
+func (cfg *Cfg) targetsCfgError() error {
+	// The default target is only optional when other targets are defined
+	defaultTarget := len(cfg.Targets) == 0 || cfg.APIKey != "" || cfg.SourceID != ""
+	if defaultTarget && (cfg.APIKey == "" || cfg.SourceID == "") {
+		return fmt.Errorf("the %v output requires api_key and source_id to be defined in the nsone section", NS1Type)
+	}
+
+	names := make(map[string]bool)
+	for i, target := range cfg.Targets {
+		switch {
+		case target.Name == "":
+			return fmt.Errorf("NS1 target %d: name must be defined", i)
+		case target.Name == DefaultTarget:
+			return fmt.Errorf("NS1 target %d: name [%v] is reserved for the api_key and source_id of the nsone section", i, DefaultTarget)
+		case names[target.Name]:
+			return fmt.Errorf("NS1 target [%v] is duplicated", target.Name)
+		case target.APIKey == "" || target.SourceID == "":
+			return fmt.Errorf("NS1 target %v requires api_key and source_id to be defined", target.Name)
+		case target.ClientTimeout < 0:
+			return fmt.Errorf("NS1 target %v: client_timeout can't be negative", target.Name)
+		}
+		names[target.Name] = true
+	}
+	return nil
+}
+
+// ns1Targets returns the names of the NS1 targets the feed is published to
+func (f *Feed) ns1Targets() []string {
+	if len(f.Targets) == 0 {
+		return []string{DefaultTarget}
+	}
+	return f.Targets
+}
+
+// newNS1Pusher returns the Pusher of the nsone output. Every NS1 target has its own metrics, retries and change
+// detection, so a target failing doesn't affect the rest of them.
+func newNS1Pusher(nsoneCfg *Cfg, retryCfg *RetryCfg, feeds []Feed) (Pusher, error) {
+	if err := nsoneCfg.targetsCfgError(); err != nil {
+		return nil, err
+	}
+
+	cfgs := nsoneCfg.targetCfgs()
+	tp := &targetsPusher{
+		pushers:     make(map[string]Pusher, len(cfgs)),
+		feedTargets: make(map[string][]string, len(feeds)),
+	}
+	for i := range feeds {
+		targets := feeds[i].ns1Targets()
+		for _, target := range targets {
+			if _, ok := cfgs[target]; !ok {
+				return nil, fmt.Errorf("feed %v: NS1 target [%v] is not defined in the nsone section", feeds[i].FeedName, target)
+			}
+		}
+		tp.feedTargets[feeds[i].FeedName] = targets
+	}
+
+	for name := range cfgs {
+		tp.names = append(tp.names, name)
+	}
+	sort.Strings(tp.names)
+
+	for _, name := range tp.names {
+		pusher, err := newNS1TargetPusher(name, cfgs[name], retryCfg)
+		if err != nil {
+			// The pushers of the targets already configured are not used
+			_ = tp.Close()
+			return nil, fmt.Errorf("NS1 target %v: %w", name, err)
+		}
+		tp.pushers[name] = pusher
+	}
+
+	if len(tp.pushers) == 1 {
+		return tp.pushers[tp.names[0]], nil
+	}
+	return tp, nil
+}
+
+// newNS1TargetPusher returns the Pusher of an NS1 target, with the metrics labeled with the name of the target
+func newNS1TargetPusher(name string, cfg *Cfg, retryCfg *RetryCfg) (Pusher, error) {
+	ns1 := &NS1{}
+	if err := ns1.Configure(cfg); err != nil {
+		return nil, err
+	}
+
+	label := NS1Type
+	if name != DefaultTarget {
+		label = fmt.Sprintf("%v/%v", NS1Type, name)
+	}
+	pusher, err := wrapPusher(ns1, label, retryCfg, cfg)
+	if err != nil {
+		_ = ns1.Close()
+		return nil, err
+	}
+	return pusher, nil
+}
+
+// targetsPusher pushes the data of every feed to the NS1 targets it's published to
+type targetsPusher struct {
+	// pushers are the pushers of the NS1 targets by name
+	pushers map[string]Pusher
+	// names are the sorted names of the NS1 targets
+	names []string
+	// feedTargets are the names of the NS1 targets of every feed, by feed name
+	feedTargets map[string][]string
+}
+
+// Push sends to every NS1 target the data of its feeds. An error in one of the targets does not prevent sending
+// the data to the rest.
+func (tp *targetsPusher) Push(ctx context.Context, data map[string]*internal.FeedData) error {
+	byTarget := make(map[string]map[string]*internal.FeedData)
+	for feed, feedData := range data {
+		for _, target := range tp.targetsOf(feed) {
+			if byTarget[target] == nil {
+				byTarget[target] = make(map[string]*internal.FeedData)
+			}
+			byTarget[target][feed] = feedData
+		}
+	}
+
+	var errs []string
+	for _, name := range tp.names {
+		if len(byTarget[name]) == 0 {
+			continue
+		}
+		if err := tp.pushers[name].Push(ctx, byTarget[name]); err != nil {
+			errs = append(errs, fmt.Sprintf("target %v: %v", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error pushing to %d of %d NS1 targets: %v", len(errs), len(byTarget), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ValidateFeeds checks that every NS1 target is able to receive the data of its feeds
+func (tp *targetsPusher) ValidateFeeds(feedNames []string) error {
+	byTarget := make(map[string][]string)
+	for _, feed := range feedNames {
+		for _, target := range tp.targetsOf(feed) {
+			byTarget[target] = append(byTarget[target], feed)
+		}
+	}
+
+	for _, name := range tp.names {
+		if len(byTarget[name]) == 0 {
+			continue
+		}
+		if err := tp.pushers[name].ValidateFeeds(byTarget[name]); err != nil {
+			return fmt.Errorf("NS1 target %v: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Close closes the pushers of all the NS1 targets
+func (tp *targetsPusher) Close() error {
+	var errs []string
+	for _, name := range tp.names {
+		pusher, ok := tp.pushers[name]
+		if !ok {
+			continue
+		}
+		if err := pusher.Close(); err != nil {
+			errs = append(errs, fmt.Sprintf("target %v: %v", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error closing %d of %d NS1 targets: %v", len(errs), len(tp.names), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (tp *targetsPusher) targetsOf(feed string) []string {
+	if targets, ok := tp.feedTargets[feed]; ok {
+		return targets
+	}
+	return []string{DefaultTarget}
+}
@@ -0,0 +1,120 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+)
+
+func TestTargetsCfgError(t *testing.T) {
+	testCases := []struct {
+		cfg     *Cfg
+		wantErr bool
+		msg     string
+	}{
+		{
+			cfg:     &Cfg{APIKey: "key", SourceID: "source"},
+			wantErr: false,
+			msg:     "default target only",
+		},
+		{
+			cfg:     &Cfg{Targets: []TargetCfg{{Name: "dr", APIKey: "key", SourceID: "source"}}},
+			wantErr: false,
+			msg:     "named targets without the default target",
+		},
+		{
+			cfg:     &Cfg{APIKey: "key", Targets: []TargetCfg{{Name: "dr", APIKey: "key", SourceID: "source"}}},
+			wantErr: true,
+			msg:     "default target without source_id",
+		},
+		{
+			cfg:     &Cfg{Targets: []TargetCfg{{APIKey: "key", SourceID: "source"}}},
+			wantErr: true,
+			msg:     "target without name",
+		},
+		{
+			cfg:     &Cfg{Targets: []TargetCfg{{Name: DefaultTarget, APIKey: "key", SourceID: "source"}}},
+			wantErr: true,
+			msg:     "target with the reserved name",
+		},
+		{
+			cfg: &Cfg{Targets: []TargetCfg{
+				{Name: "dr", APIKey: "key", SourceID: "source"},
+				{Name: "dr", APIKey: "other-key", SourceID: "other-source"},
+			}},
+			wantErr: true,
+			msg:     "duplicated target",
+		},
+		{
+			cfg:     &Cfg{Targets: []TargetCfg{{Name: "dr", SourceID: "source"}}},
+			wantErr: true,
+			msg:     "target without api_key",
+		},
+	}
+
+	for _, testCase := range testCases {
+		err := testCase.cfg.targetsCfgError()
+		if err == nil && testCase.wantErr {
+			t.Errorf("targetsCfgError returned <nil>, but an error was expected for case: %v", testCase.msg)
+		}
+		if err != nil && !testCase.wantErr {
+			t.Errorf("targetsCfgError returned an err: %v for case: %v", err, testCase.msg)
+		}
+	}
+}
+
+func TestNewNS1Pusher(t *testing.T) {
+	cfg := &Cfg{
+		APIKey:        "key",
+		SourceID:      "source",
+		ClientTimeout: 10,
+		Targets:       []TargetCfg{{Name: "dr", APIKey: "other-key", SourceID: "other-source"}},
+	}
+
+	pusher, err := newNS1Pusher(cfg, nil, []Feed{{FeedName: "feed01", Targets: []string{DefaultTarget, "dr"}}})
+	if err != nil {
+		t.Fatalf("newNS1Pusher returned an err: %v", err)
+	}
+	tp, ok := pusher.(*targetsPusher)
+	if !ok {
+		t.Fatalf("newNS1Pusher returned %T, but *targetsPusher expected with several targets", pusher)
+	}
+	if ns1 := tp.pushers["dr"].(*measuredPusher).Pusher.(*NS1); ns1.Cfg.SourceID != "other-source" || ns1.Cfg.ClientTimeout != 10 {
+		t.Errorf("newNS1Pusher configured the target dr with %+v, but its source_id and the client_timeout of the nsone section expected", ns1.Cfg)
+	}
+
+	if _, err := newNS1Pusher(cfg, nil, []Feed{{FeedName: "feed01", Targets: []string{"qa"}}}); err == nil {
+		t.Errorf("newNS1Pusher err returned <nil>, but an error was expected for a feed with an undefined target")
+	}
+}
+
+func TestTargetsPusherPush(t *testing.T) {
+	prod := &fakePusher{}
+	dr := &fakePusher{err: errors.New("push error")}
+	tp := &targetsPusher{
+		pushers: map[string]Pusher{DefaultTarget: prod, "dr": dr},
+		names:   []string{DefaultTarget, "dr"},
+		feedTargets: map[string][]string{
+			"feed01": {DefaultTarget},
+			"feed02": {DefaultTarget, "dr"},
+		},
+	}
+
+	data := map[string]*internal.FeedData{
+		"feed01": {Connections: 1, Up: true},
+		"feed02": {Connections: 2, Up: true},
+		"feed03": {Connections: 3, Up: true},
+	}
+	err := tp.Push(context.Background(), data)
+	if err == nil {
+		t.Errorf("targetsPusher.Push err returned <nil>, but an error was expected because one of the targets failed")
+	}
+	if len(prod.pushed) != 3 {
+		t.Errorf("targetsPusher.Push pushed %v to the default target, but all the feeds expected", prod.pushed)
+	}
+	if _, ok := dr.pushed["feed02"]; !ok || len(dr.pushed) != 1 {
+		t.Errorf("targetsPusher.Push pushed %v to the target dr, but only feed02 expected", dr.pushed)
+	}
+}
@@ -0,0 +1,81 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+)
+
+// Webhook sends the data of the feeds as a JSON object to a generic HTTP endpoint using POST
+type Webhook struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// Configure sets the configuration for the Webhook client
+func (w *Webhook) Configure(cfg *SinkCfg) error {
+	if cfg.URL == "" {
+		return fmt.Errorf("the webhook output requires a url to be defined")
+	}
+
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("the webhook output requires a valid http or https url, got %v", cfg.URL)
+	}
+
+	w.url = cfg.URL
+	w.headers = cfg.Headers
+	w.client = &http.Client{Timeout: time.Duration(cfg.ClientTimeout) * time.Second}
+	return nil
+}
+
+// Push sends the data to the webhook. Any response other than 2xx is considered an error.
+func (w *Webhook) Push(ctx context.Context, data map[string]*internal.FeedData) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error encoding the data: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating the webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range w.headers {
+		req.Header.Set(name, value)
+	}
+
+	log.Printf("Pushing data to webhook %v", w.url)
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling the webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	// The body is drained so the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return newHTTPPushError(fmt.Errorf("webhook %v returned status %v", w.url, resp.StatusCode), resp)
+	}
+	return nil
+}
+
+// ValidateFeeds accepts any feed name
+func (w *Webhook) ValidateFeeds(_ []string) error {
+	return nil
+}
+
+// Close closes the idle connections to the webhook
+func (w *Webhook) Close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,57 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/nginxinc/nginx-ns1-gslb/internal"
+)
+
+func TestWebhookPush(t *testing.T) {
+	var received map[string]*internal.FeedData
+	var token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("X-Token")
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	webhook := &Webhook{}
+	err := webhook.Configure(&SinkCfg{Type: WebhookType, URL: server.URL, Headers: map[string]string{"X-Token": "secret"}, ClientTimeout: 1})
+	if err != nil {
+		t.Fatalf("Webhook configuration returned an unexpected error: %v", err)
+	}
+
+	data := map[string]*internal.FeedData{"feed01": {Connections: 10, Up: true}}
+	if err := webhook.Push(context.Background(), data); err != nil {
+		t.Errorf("Webhook.Push returned an unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(data, received) {
+		t.Errorf("Webhook.Push sent %v, but %v expected", received, data)
+	}
+	if token != "secret" {
+		t.Errorf("Webhook.Push sent the header X-Token=%v, but %v expected", token, "secret")
+	}
+}
+
+func TestWebhookPushError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	webhook := &Webhook{}
+	if err := webhook.Configure(&SinkCfg{Type: WebhookType, URL: server.URL, ClientTimeout: 1}); err != nil {
+		t.Fatalf("Webhook configuration returned an unexpected error: %v", err)
+	}
+
+	if err := webhook.Push(context.Background(), map[string]*internal.FeedData{"feed01": {Up: true}}); err == nil {
+		t.Errorf("Webhook.Push err returned <nil>, but an error was expected because the webhook returned 500")
+	}
+}